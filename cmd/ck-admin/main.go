@@ -0,0 +1,188 @@
+// ck-admin is a CLI client for a Cloak server's adminrpc.AdminService. It
+// lets panel operators script bulk provisioning and quota top-ups against
+// a running server instead of editing its bolt file in-process.
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/cbeuw/Cloak/internal/adminrpc"
+)
+
+func main() {
+	var (
+		addr       = flag.String("addr", "127.0.0.1:9999", "admin service address")
+		certFile   = flag.String("cert", "", "path to the admin client certificate")
+		keyFile    = flag.String("key", "", "path to the admin client private key")
+		caFile     = flag.String("ca", "", "path to the CA certificate that signed the server's certificate")
+		timeoutSec = flag.Int("timeout", 10, "request timeout in seconds")
+	)
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s [flags] <command> [args...]\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "commands:\n")
+		fmt.Fprintf(os.Stderr, "  add-user <uid-base64> <sessions-cap> <up-rate> <down-rate> <up-credit> <down-credit> <expiry-unix>\n")
+		fmt.Fprintf(os.Stderr, "  del-user <uid-base64>\n")
+		fmt.Fprintf(os.Stderr, "  set-up-credit <uid-base64> <value>\n")
+		fmt.Fprintf(os.Stderr, "  set-down-credit <uid-base64> <value>\n")
+		fmt.Fprintf(os.Stderr, "  add-up-credit <uid-base64> <delta>\n")
+		fmt.Fprintf(os.Stderr, "  add-down-credit <uid-base64> <delta>\n")
+		fmt.Fprintf(os.Stderr, "  list-all\n")
+		fmt.Fprintf(os.Stderr, "  list-active\n")
+		fmt.Fprintf(os.Stderr, "  get-user <uid-base64>\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	args := flag.Args()
+	if len(args) < 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	clientCert, err := tls.LoadX509KeyPair(*certFile, *keyFile)
+	if err != nil {
+		log.Fatalf("failed to load client certificate: %v", err)
+	}
+	caBytes, err := ioutil.ReadFile(*caFile)
+	if err != nil {
+		log.Fatalf("failed to read CA certificate: %v", err)
+	}
+	caPool := x509.NewCertPool()
+	if !caPool.AppendCertsFromPEM(caBytes) {
+		log.Fatalf("failed to parse CA certificate")
+	}
+
+	cli, conn, err := adminrpc.Dial(*addr, clientCert, caPool)
+	if err != nil {
+		log.Fatalf("failed to dial %s: %v", *addr, err)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(*timeoutSec)*time.Second)
+	defer cancel()
+
+	if err := run(ctx, cli, args); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run(ctx context.Context, cli adminrpc.AdminServiceClient, args []string) error {
+	switch args[0] {
+	case "add-user":
+		if len(args) != 8 {
+			return fmt.Errorf("add-user takes 7 arguments")
+		}
+		uid, err := base64.StdEncoding.DecodeString(args[1])
+		if err != nil {
+			return err
+		}
+		sessionsCap, _ := strconv.ParseUint(args[2], 10, 32)
+		upRate, _ := strconv.ParseInt(args[3], 10, 64)
+		downRate, _ := strconv.ParseInt(args[4], 10, 64)
+		upCredit, _ := strconv.ParseInt(args[5], 10, 64)
+		downCredit, _ := strconv.ParseInt(args[6], 10, 64)
+		expiryTime, _ := strconv.ParseInt(args[7], 10, 64)
+		ack, err := cli.AddNewUser(ctx, &adminrpc.AddNewUserRequest{Info: &adminrpc.UserInfo{
+			Uid:         uid,
+			SessionsCap: uint32(sessionsCap),
+			UpRate:      upRate,
+			DownRate:    downRate,
+			UpCredit:    upCredit,
+			DownCredit:  downCredit,
+			ExpiryTime:  expiryTime,
+		}})
+		return printAck(ack, err)
+	case "del-user":
+		uid, err := decodeUID(args)
+		if err != nil {
+			return err
+		}
+		ack, err := cli.DelUser(ctx, &adminrpc.UIDRequest{Uid: uid})
+		return printAck(ack, err)
+	case "set-up-credit", "set-down-credit", "add-up-credit", "add-down-credit":
+		if len(args) != 3 {
+			return fmt.Errorf("%s takes 2 arguments", args[0])
+		}
+		uid, err := base64.StdEncoding.DecodeString(args[1])
+		if err != nil {
+			return err
+		}
+		value, err := strconv.ParseInt(args[2], 10, 64)
+		if err != nil {
+			return err
+		}
+		req := &adminrpc.SetInt64Request{Uid: uid, Value: value}
+		var ack *adminrpc.Ack
+		switch args[0] {
+		case "set-up-credit":
+			ack, err = cli.SetUpCredit(ctx, req)
+		case "set-down-credit":
+			ack, err = cli.SetDownCredit(ctx, req)
+		case "add-up-credit":
+			ack, err = cli.AddUpCredit(ctx, req)
+		case "add-down-credit":
+			ack, err = cli.AddDownCredit(ctx, req)
+		}
+		return printAck(ack, err)
+	case "list-all":
+		list, err := cli.ListAllUsers(ctx, &adminrpc.Empty{})
+		if err != nil {
+			return err
+		}
+		for _, u := range list.Users {
+			fmt.Printf("%s\tsessionsCap=%d\tupCredit=%d\tdownCredit=%d\texpiry=%d\n",
+				base64.StdEncoding.EncodeToString(u.Uid), u.SessionsCap, u.UpCredit, u.DownCredit, u.ExpiryTime)
+		}
+		return nil
+	case "list-active":
+		list, err := cli.ListActiveUsers(ctx, &adminrpc.Empty{})
+		if err != nil {
+			return err
+		}
+		for _, uid := range list.Uid {
+			fmt.Println(base64.StdEncoding.EncodeToString(uid))
+		}
+		return nil
+	case "get-user":
+		uid, err := decodeUID(args)
+		if err != nil {
+			return err
+		}
+		u, err := cli.GetUserInfo(ctx, &adminrpc.UIDRequest{Uid: uid})
+		if err != nil {
+			return err
+		}
+		fmt.Printf("sessionsCap=%d upRate=%d downRate=%d upCredit=%d downCredit=%d expiry=%d\n",
+			u.SessionsCap, u.UpRate, u.DownRate, u.UpCredit, u.DownCredit, u.ExpiryTime)
+		return nil
+	default:
+		return fmt.Errorf("unknown command: %s", args[0])
+	}
+}
+
+func decodeUID(args []string) ([]byte, error) {
+	if len(args) != 2 {
+		return nil, fmt.Errorf("%s takes 1 argument", args[0])
+	}
+	return base64.StdEncoding.DecodeString(args[1])
+}
+
+func printAck(ack *adminrpc.Ack, err error) error {
+	if err != nil {
+		return err
+	}
+	if !ack.Ok {
+		return fmt.Errorf("server returned error: %s", ack.Error)
+	}
+	fmt.Println("ok")
+	return nil
+}
@@ -0,0 +1,51 @@
+// Message types mirroring adminrpc.proto. These are hand-maintained, not
+// protoc-gen-go output: they don't implement proto.Message, so they ride
+// over the jsonCodec registered in codec.go instead of wire-format
+// protobuf. Keep the field set and protobuf tags in sync with
+// adminrpc.proto by hand when either changes.
+
+package adminrpc
+
+type Empty struct{}
+
+type Ack struct {
+	Ok    bool   `protobuf:"varint,1,opt,name=ok,proto3" json:"ok,omitempty"`
+	Error string `protobuf:"bytes,2,opt,name=error,proto3" json:"error,omitempty"`
+}
+
+type UIDRequest struct {
+	Uid []byte `protobuf:"bytes,1,opt,name=uid,proto3" json:"uid,omitempty"`
+}
+
+type UIDList struct {
+	Uid [][]byte `protobuf:"bytes,1,rep,name=uid,proto3" json:"uid,omitempty"`
+}
+
+type UserInfo struct {
+	Uid           []byte `protobuf:"bytes,1,opt,name=uid,proto3" json:"uid,omitempty"`
+	SessionsCap   uint32 `protobuf:"varint,2,opt,name=sessions_cap,json=sessionsCap,proto3" json:"sessions_cap,omitempty"`
+	UpRate        int64  `protobuf:"varint,3,opt,name=up_rate,json=upRate,proto3" json:"up_rate,omitempty"`
+	DownRate      int64  `protobuf:"varint,4,opt,name=down_rate,json=downRate,proto3" json:"down_rate,omitempty"`
+	UpCredit      int64  `protobuf:"varint,5,opt,name=up_credit,json=upCredit,proto3" json:"up_credit,omitempty"`
+	DownCredit    int64  `protobuf:"varint,6,opt,name=down_credit,json=downCredit,proto3" json:"down_credit,omitempty"`
+	ExpiryTime    int64  `protobuf:"varint,7,opt,name=expiry_time,json=expiryTime,proto3" json:"expiry_time,omitempty"`
+	LastSeenMicro int64  `protobuf:"varint,8,opt,name=last_seen_micro,json=lastSeenMicro,proto3" json:"last_seen_micro,omitempty"`
+}
+
+type UserInfoList struct {
+	Users []*UserInfo `protobuf:"bytes,1,rep,name=users,proto3" json:"users,omitempty"`
+}
+
+type AddNewUserRequest struct {
+	Info *UserInfo `protobuf:"bytes,1,opt,name=info,proto3" json:"info,omitempty"`
+}
+
+type SetUint32Request struct {
+	Uid   []byte `protobuf:"bytes,1,opt,name=uid,proto3" json:"uid,omitempty"`
+	Value uint32 `protobuf:"varint,2,opt,name=value,proto3" json:"value,omitempty"`
+}
+
+type SetInt64Request struct {
+	Uid   []byte `protobuf:"bytes,1,opt,name=uid,proto3" json:"uid,omitempty"`
+	Value int64  `protobuf:"varint,2,opt,name=value,proto3" json:"value,omitempty"`
+}
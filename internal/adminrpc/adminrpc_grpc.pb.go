@@ -0,0 +1,412 @@
+// Client/server stubs mirroring adminrpc.proto's service definition.
+// Hand-maintained alongside adminrpc.pb.go rather than generated by
+// protoc-gen-go-grpc; see codec.go for how messages actually get onto the
+// wire.
+
+package adminrpc
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+)
+
+const (
+	AdminService_ServiceDesc_ServiceName = "adminrpc.AdminService"
+)
+
+// AdminServiceClient is the client API for AdminService.
+type AdminServiceClient interface {
+	AddNewUser(ctx context.Context, in *AddNewUserRequest, opts ...grpc.CallOption) (*Ack, error)
+	DelUser(ctx context.Context, in *UIDRequest, opts ...grpc.CallOption) (*Ack, error)
+	SetSessionsCap(ctx context.Context, in *SetUint32Request, opts ...grpc.CallOption) (*Ack, error)
+	SetUpRate(ctx context.Context, in *SetInt64Request, opts ...grpc.CallOption) (*Ack, error)
+	SetDownRate(ctx context.Context, in *SetInt64Request, opts ...grpc.CallOption) (*Ack, error)
+	SetUpCredit(ctx context.Context, in *SetInt64Request, opts ...grpc.CallOption) (*Ack, error)
+	SetDownCredit(ctx context.Context, in *SetInt64Request, opts ...grpc.CallOption) (*Ack, error)
+	AddUpCredit(ctx context.Context, in *SetInt64Request, opts ...grpc.CallOption) (*Ack, error)
+	AddDownCredit(ctx context.Context, in *SetInt64Request, opts ...grpc.CallOption) (*Ack, error)
+	SetExpiryTime(ctx context.Context, in *SetInt64Request, opts ...grpc.CallOption) (*Ack, error)
+	ListAllUsers(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*UserInfoList, error)
+	ListActiveUsers(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*UIDList, error)
+	GetUserInfo(ctx context.Context, in *UIDRequest, opts ...grpc.CallOption) (*UserInfo, error)
+	SyncMemFromDB(ctx context.Context, in *UIDRequest, opts ...grpc.CallOption) (*Ack, error)
+}
+
+type adminServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewAdminServiceClient(cc grpc.ClientConnInterface) AdminServiceClient {
+	return &adminServiceClient{cc}
+}
+
+func (c *adminServiceClient) call(ctx context.Context, method string, in, out interface{}, opts ...grpc.CallOption) error {
+	return c.cc.Invoke(ctx, "/"+AdminService_ServiceDesc_ServiceName+"/"+method, in, out, opts...)
+}
+
+func (c *adminServiceClient) AddNewUser(ctx context.Context, in *AddNewUserRequest, opts ...grpc.CallOption) (*Ack, error) {
+	out := new(Ack)
+	if err := c.call(ctx, "AddNewUser", in, out, opts...); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) DelUser(ctx context.Context, in *UIDRequest, opts ...grpc.CallOption) (*Ack, error) {
+	out := new(Ack)
+	if err := c.call(ctx, "DelUser", in, out, opts...); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) SetSessionsCap(ctx context.Context, in *SetUint32Request, opts ...grpc.CallOption) (*Ack, error) {
+	out := new(Ack)
+	if err := c.call(ctx, "SetSessionsCap", in, out, opts...); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) SetUpRate(ctx context.Context, in *SetInt64Request, opts ...grpc.CallOption) (*Ack, error) {
+	out := new(Ack)
+	if err := c.call(ctx, "SetUpRate", in, out, opts...); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) SetDownRate(ctx context.Context, in *SetInt64Request, opts ...grpc.CallOption) (*Ack, error) {
+	out := new(Ack)
+	if err := c.call(ctx, "SetDownRate", in, out, opts...); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) SetUpCredit(ctx context.Context, in *SetInt64Request, opts ...grpc.CallOption) (*Ack, error) {
+	out := new(Ack)
+	if err := c.call(ctx, "SetUpCredit", in, out, opts...); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) SetDownCredit(ctx context.Context, in *SetInt64Request, opts ...grpc.CallOption) (*Ack, error) {
+	out := new(Ack)
+	if err := c.call(ctx, "SetDownCredit", in, out, opts...); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) AddUpCredit(ctx context.Context, in *SetInt64Request, opts ...grpc.CallOption) (*Ack, error) {
+	out := new(Ack)
+	if err := c.call(ctx, "AddUpCredit", in, out, opts...); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) AddDownCredit(ctx context.Context, in *SetInt64Request, opts ...grpc.CallOption) (*Ack, error) {
+	out := new(Ack)
+	if err := c.call(ctx, "AddDownCredit", in, out, opts...); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) SetExpiryTime(ctx context.Context, in *SetInt64Request, opts ...grpc.CallOption) (*Ack, error) {
+	out := new(Ack)
+	if err := c.call(ctx, "SetExpiryTime", in, out, opts...); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) ListAllUsers(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*UserInfoList, error) {
+	out := new(UserInfoList)
+	if err := c.call(ctx, "ListAllUsers", in, out, opts...); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) ListActiveUsers(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*UIDList, error) {
+	out := new(UIDList)
+	if err := c.call(ctx, "ListActiveUsers", in, out, opts...); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) GetUserInfo(ctx context.Context, in *UIDRequest, opts ...grpc.CallOption) (*UserInfo, error) {
+	out := new(UserInfo)
+	if err := c.call(ctx, "GetUserInfo", in, out, opts...); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) SyncMemFromDB(ctx context.Context, in *UIDRequest, opts ...grpc.CallOption) (*Ack, error) {
+	out := new(Ack)
+	if err := c.call(ctx, "SyncMemFromDB", in, out, opts...); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// AdminServiceServer is the server API for AdminService.
+type AdminServiceServer interface {
+	AddNewUser(context.Context, *AddNewUserRequest) (*Ack, error)
+	DelUser(context.Context, *UIDRequest) (*Ack, error)
+	SetSessionsCap(context.Context, *SetUint32Request) (*Ack, error)
+	SetUpRate(context.Context, *SetInt64Request) (*Ack, error)
+	SetDownRate(context.Context, *SetInt64Request) (*Ack, error)
+	SetUpCredit(context.Context, *SetInt64Request) (*Ack, error)
+	SetDownCredit(context.Context, *SetInt64Request) (*Ack, error)
+	AddUpCredit(context.Context, *SetInt64Request) (*Ack, error)
+	AddDownCredit(context.Context, *SetInt64Request) (*Ack, error)
+	SetExpiryTime(context.Context, *SetInt64Request) (*Ack, error)
+	ListAllUsers(context.Context, *Empty) (*UserInfoList, error)
+	ListActiveUsers(context.Context, *Empty) (*UIDList, error)
+	GetUserInfo(context.Context, *UIDRequest) (*UserInfo, error)
+	SyncMemFromDB(context.Context, *UIDRequest) (*Ack, error)
+}
+
+func RegisterAdminServiceServer(s grpc.ServiceRegistrar, srv AdminServiceServer) {
+	s.RegisterService(&adminServiceServiceDesc, srv)
+}
+
+var adminServiceServiceDesc = grpc.ServiceDesc{
+	ServiceName: AdminService_ServiceDesc_ServiceName,
+	HandlerType: (*AdminServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{MethodName: "AddNewUser", Handler: _AdminService_AddNewUser_Handler},
+		{MethodName: "DelUser", Handler: _AdminService_DelUser_Handler},
+		{MethodName: "SetSessionsCap", Handler: _AdminService_SetSessionsCap_Handler},
+		{MethodName: "SetUpRate", Handler: _AdminService_SetUpRate_Handler},
+		{MethodName: "SetDownRate", Handler: _AdminService_SetDownRate_Handler},
+		{MethodName: "SetUpCredit", Handler: _AdminService_SetUpCredit_Handler},
+		{MethodName: "SetDownCredit", Handler: _AdminService_SetDownCredit_Handler},
+		{MethodName: "AddUpCredit", Handler: _AdminService_AddUpCredit_Handler},
+		{MethodName: "AddDownCredit", Handler: _AdminService_AddDownCredit_Handler},
+		{MethodName: "SetExpiryTime", Handler: _AdminService_SetExpiryTime_Handler},
+		{MethodName: "ListAllUsers", Handler: _AdminService_ListAllUsers_Handler},
+		{MethodName: "ListActiveUsers", Handler: _AdminService_ListActiveUsers_Handler},
+		{MethodName: "GetUserInfo", Handler: _AdminService_GetUserInfo_Handler},
+		{MethodName: "SyncMemFromDB", Handler: _AdminService_SyncMemFromDB_Handler},
+	},
+	Metadata: "adminrpc.proto",
+}
+
+func _AdminService_AddNewUser_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AddNewUserRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).AddNewUser(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + AdminService_ServiceDesc_ServiceName + "/AddNewUser"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).AddNewUser(ctx, req.(*AddNewUserRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_DelUser_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UIDRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).DelUser(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + AdminService_ServiceDesc_ServiceName + "/DelUser"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).DelUser(ctx, req.(*UIDRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_SetSessionsCap_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetUint32Request)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).SetSessionsCap(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + AdminService_ServiceDesc_ServiceName + "/SetSessionsCap"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).SetSessionsCap(ctx, req.(*SetUint32Request))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_SetUpRate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetInt64Request)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).SetUpRate(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + AdminService_ServiceDesc_ServiceName + "/SetUpRate"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).SetUpRate(ctx, req.(*SetInt64Request))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_SetDownRate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetInt64Request)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).SetDownRate(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + AdminService_ServiceDesc_ServiceName + "/SetDownRate"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).SetDownRate(ctx, req.(*SetInt64Request))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_SetUpCredit_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetInt64Request)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).SetUpCredit(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + AdminService_ServiceDesc_ServiceName + "/SetUpCredit"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).SetUpCredit(ctx, req.(*SetInt64Request))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_SetDownCredit_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetInt64Request)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).SetDownCredit(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + AdminService_ServiceDesc_ServiceName + "/SetDownCredit"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).SetDownCredit(ctx, req.(*SetInt64Request))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_AddUpCredit_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetInt64Request)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).AddUpCredit(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + AdminService_ServiceDesc_ServiceName + "/AddUpCredit"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).AddUpCredit(ctx, req.(*SetInt64Request))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_AddDownCredit_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetInt64Request)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).AddDownCredit(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + AdminService_ServiceDesc_ServiceName + "/AddDownCredit"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).AddDownCredit(ctx, req.(*SetInt64Request))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_SetExpiryTime_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetInt64Request)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).SetExpiryTime(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + AdminService_ServiceDesc_ServiceName + "/SetExpiryTime"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).SetExpiryTime(ctx, req.(*SetInt64Request))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_ListAllUsers_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).ListAllUsers(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + AdminService_ServiceDesc_ServiceName + "/ListAllUsers"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).ListAllUsers(ctx, req.(*Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_ListActiveUsers_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).ListActiveUsers(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + AdminService_ServiceDesc_ServiceName + "/ListActiveUsers"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).ListActiveUsers(ctx, req.(*Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_GetUserInfo_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UIDRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).GetUserInfo(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + AdminService_ServiceDesc_ServiceName + "/GetUserInfo"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).GetUserInfo(ctx, req.(*UIDRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_SyncMemFromDB_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UIDRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).SyncMemFromDB(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + AdminService_ServiceDesc_ServiceName + "/SyncMemFromDB"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).SyncMemFromDB(ctx, req.(*UIDRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
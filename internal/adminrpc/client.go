@@ -0,0 +1,24 @@
+package adminrpc
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// Dial connects to an AdminService listening at addr, authenticating with
+// clientCert and trusting server certificates signed by serverCAs. The
+// returned client's calls all carry clientCert's identity as the admin UID.
+func Dial(addr string, clientCert tls.Certificate, serverCAs *x509.CertPool) (AdminServiceClient, *grpc.ClientConn, error) {
+	creds := credentials.NewTLS(&tls.Config{
+		Certificates: []tls.Certificate{clientCert},
+		RootCAs:      serverCAs,
+	})
+	conn, err := grpc.Dial(addr, grpc.WithTransportCredentials(creds), clientCodecOption())
+	if err != nil {
+		return nil, nil, err
+	}
+	return NewAdminServiceClient(conn), conn, nil
+}
@@ -0,0 +1,52 @@
+package adminrpc
+
+import (
+	"encoding/json"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/encoding"
+)
+
+// jsonCodecName is the content-subtype adminrpc's messages are marshaled
+// under. It is a distinct, package-namespaced name rather than grpc's
+// default "proto", and callers must select it explicitly (serverCodecOption
+// on the server, clientCodecOption on the client) instead of relying on
+// content-subtype negotiation — registering it as "proto" would globally
+// override grpc-go's built-in codec for the whole process the moment this
+// package is imported, silently breaking any other real protobuf-based
+// gRPC client/server sharing the binary.
+const jsonCodecName = "adminrpc-json"
+
+// jsonCodec marshals adminrpc's request/response types as JSON. The types
+// in adminrpc.pb.go are plain structs carrying only struct tags, not real
+// protoc-gen-go output, so they fail the proto.Message type assertion
+// grpc's built-in "proto" codec requires.
+type jsonCodec struct{}
+
+func (jsonCodec) Name() string { return jsonCodecName }
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+func init() {
+	encoding.RegisterCodec(jsonCodec{})
+}
+
+// serverCodecOption forces every RPC on the resulting *grpc.Server to be
+// marshaled with jsonCodec, regardless of the request's content-subtype,
+// so AdminService doesn't depend on clients negotiating it.
+func serverCodecOption() grpc.ServerOption {
+	return grpc.ForceServerCodec(jsonCodec{})
+}
+
+// clientCodecOption makes every call on the resulting ClientConn use
+// jsonCodec by default, the client-side counterpart of
+// serverCodecOption.
+func clientCodecOption() grpc.DialOption {
+	return grpc.WithDefaultCallOptions(grpc.CallContentSubtype(jsonCodecName))
+}
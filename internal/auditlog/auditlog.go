@@ -0,0 +1,70 @@
+// Package auditlog emits a structured, append-only JSON record for every
+// admin mutation against the user db, so operators have a trail of who
+// changed what without having to diff bolt snapshots by hand.
+package auditlog
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// Entry is one audit record. Before/After are left as interface{} so
+// callers can log whatever shape is meaningful for the action (a whole
+// UserInfo for addNewUser, a single credit value for setUpCredit, etc.).
+type Entry struct {
+	Time   time.Time   `json:"time"`
+	Actor  string      `json:"actor"`
+	Action string      `json:"action"`
+	UID    string      `json:"uid,omitempty"`
+	Before interface{} `json:"before,omitempty"`
+	After  interface{} `json:"after,omitempty"`
+	Error  string      `json:"error,omitempty"`
+}
+
+// Logger appends one JSON line per Log call to an underlying writer. It's
+// safe for concurrent use.
+type Logger struct {
+	w  io.Writer
+	mu sync.Mutex
+	// Now is overridable in tests; defaults to time.Now.
+	Now func() time.Time
+}
+
+// New wraps w, which is typically an append-mode *os.File.
+func New(w io.Writer) *Logger {
+	return &Logger{w: w, Now: time.Now}
+}
+
+// Log records one admin mutation. actorUID and uid are raw UIDs and are
+// base64-encoded for the record; err is the outcome of the mutation
+// itself, not of logging.
+func (l *Logger) Log(actorUID []byte, action string, uid []byte, before, after interface{}, err error) {
+	if l == nil {
+		return
+	}
+	entry := Entry{
+		Time:   l.Now(),
+		Actor:  base64.StdEncoding.EncodeToString(actorUID),
+		Action: action,
+		Before: before,
+		After:  after,
+	}
+	if uid != nil {
+		entry.UID = base64.StdEncoding.EncodeToString(uid)
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	line, merr := json.Marshal(entry)
+	if merr != nil {
+		return
+	}
+	line = append(line, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.w.Write(line)
+}
@@ -6,20 +6,77 @@ import (
 	"encoding/json"
 	"errors"
 	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v2"
+
 	"github.com/cbeuw/Cloak/internal/ecdh"
 )
 
 type rawConfig struct {
-	ServerName     string
-	UID            string
-	PublicKey      string
-	TicketTimeHint int
-	MaskBrowser    string
-	NumConn        int
+	ServerName     string `json:"ServerName" toml:"ServerName" yaml:"ServerName"`
+	UID            string `json:"UID" toml:"UID" yaml:"UID"`
+	PublicKey      string `json:"PublicKey" toml:"PublicKey" yaml:"PublicKey"`
+	TicketTimeHint int    `json:"TicketTimeHint" toml:"TicketTimeHint" yaml:"TicketTimeHint"`
+	MaskBrowser    string `json:"MaskBrowser" toml:"MaskBrowser" yaml:"MaskBrowser"`
+	NumConn        int    `json:"NumConn" toml:"NumConn" yaml:"NumConn"`
+}
+
+// applyEnvOverrides lets any rawConfig field be overridden by a
+// CLOAK_<FIELD> environment variable, so Cloak can be embedded in
+// orchestration tools that inject config purely through the environment.
+// Env vars take precedence over whatever file or SSV string conf was
+// loaded from.
+func (c *rawConfig) applyEnvOverrides() error {
+	if v, ok := os.LookupEnv("CLOAK_SERVERNAME"); ok {
+		c.ServerName = v
+	}
+	if v, ok := os.LookupEnv("CLOAK_UID"); ok {
+		c.UID = v
+	}
+	if v, ok := os.LookupEnv("CLOAK_PUBLICKEY"); ok {
+		c.PublicKey = v
+	}
+	if v, ok := os.LookupEnv("CLOAK_MASKBROWSER"); ok {
+		c.MaskBrowser = v
+	}
+	if v, ok := os.LookupEnv("CLOAK_TICKETTIMEHINT"); ok {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return errors.New("Failed to parse CLOAK_TICKETTIMEHINT: " + err.Error())
+		}
+		c.TicketTimeHint = n
+	}
+	if v, ok := os.LookupEnv("CLOAK_NUMCONN"); ok {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return errors.New("Failed to parse CLOAK_NUMCONN: " + err.Error())
+		}
+		c.NumConn = n
+	}
+	return nil
+}
+
+// validate checks the fields that ParseConfig can't already catch via
+// decode errors, and reports them with enough context to fix the config
+// instead of a bare "illegal base64 data" panic further down the line.
+func (c *rawConfig) validate() error {
+	if c.ServerName == "" {
+		return errors.New("ServerName must not be empty")
+	}
+	if c.NumConn <= 0 || c.NumConn > 256 {
+		return errors.New("NumConn must be between 1 and 256, got " + strconv.Itoa(c.NumConn))
+	}
+	if c.TicketTimeHint <= 0 || c.TicketTimeHint > 24*60*60 {
+		return errors.New("TicketTimeHint must be between 1 and 86400 seconds, got " + strconv.Itoa(c.TicketTimeHint))
+	}
+	return nil
 }
 
 // State stores global variables
@@ -86,22 +143,50 @@ func ssvToJson(ssv string) (ret []byte) {
 	return ret
 }
 
-// ParseConfig parses the config (either a path to json or Android config) into a State variable
+// unmarshalConfigFile reads the file at path and decodes it into c,
+// choosing the codec by file extension: .toml for TOML, .yaml/.yml for
+// YAML, and JSON for everything else (including no extension at all, to
+// keep existing json-only configs working unchanged).
+func unmarshalConfigFile(path string, c *rawConfig) error {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	switch filepath.Ext(path) {
+	case ".toml":
+		return toml.Unmarshal(content, c)
+	case ".yaml", ".yml":
+		return yaml.Unmarshal(content, c)
+	default:
+		return json.Unmarshal(content, c)
+	}
+}
+
+// ParseConfig parses the config, into a State variable. conf can be the
+// Android SSV string, or a path to a JSON, TOML or YAML file, picked by
+// the file's extension (.toml, .yaml/.yml, anything else is treated as
+// JSON). Whatever rawConfig fields it reads can be overridden by
+// CLOAK_<FIELD> environment variables, which take precedence over both.
 func (sta *State) ParseConfig(conf string) (err error) {
-	var content []byte
+	var preParse rawConfig
 	if strings.Contains(conf, ";") && strings.Contains(conf, "=") {
-		content = ssvToJson(conf)
+		content := ssvToJson(conf)
+		if err = json.Unmarshal(content, &preParse); err != nil {
+			return err
+		}
 	} else {
-		content, err = ioutil.ReadFile(conf)
-		if err != nil {
+		if err = unmarshalConfigFile(conf, &preParse); err != nil {
 			return err
 		}
 	}
-	var preParse rawConfig
-	err = json.Unmarshal(content, &preParse)
-	if err != nil {
+
+	if err = preParse.applyEnvOverrides(); err != nil {
 		return err
 	}
+	if err = preParse.validate(); err != nil {
+		return err
+	}
+
 	sta.ServerName = preParse.ServerName
 	sta.TicketTimeHint = preParse.TicketTimeHint
 	sta.MaskBrowser = preParse.MaskBrowser
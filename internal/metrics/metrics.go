@@ -0,0 +1,92 @@
+// Package metrics exports Prometheus counters and gauges for per-user
+// traffic and credit, so operators can plug consumption into standard
+// alerting instead of querying the user db directly.
+package metrics
+
+import (
+	"encoding/base64"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Serve starts a plain HTTP listener on addr exposing the Prometheus
+// handler at /metrics, and blocks until it closes or errors. Callers
+// typically run it in its own goroutine alongside the admin gRPC
+// listener; addr is normally sourced from a server config's
+// MetricsListenAddr field.
+func Serve(addr string) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", Handler())
+	return http.ListenAndServe(addr, mux)
+}
+
+const uidLabel = "uid"
+
+var (
+	UpBytesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "cloak",
+		Subsystem: "user",
+		Name:      "up_bytes_total",
+		Help:      "Total bytes uploaded by a user since the process started.",
+	}, []string{uidLabel})
+
+	DownBytesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "cloak",
+		Subsystem: "user",
+		Name:      "down_bytes_total",
+		Help:      "Total bytes downloaded by a user since the process started.",
+	}, []string{uidLabel})
+
+	ActiveSessions = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "cloak",
+		Subsystem: "user",
+		Name:      "active_sessions",
+		Help:      "1 while a user is active (from activation until its sessions all close), 0 otherwise; individual sessions are not counted.",
+	}, []string{uidLabel})
+
+	UpCreditRemaining = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "cloak",
+		Subsystem: "user",
+		Name:      "up_credit_remaining",
+		Help:      "Remaining upload credit for a user, in bytes.",
+	}, []string{uidLabel})
+
+	DownCreditRemaining = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "cloak",
+		Subsystem: "user",
+		Name:      "down_credit_remaining",
+		Help:      "Remaining download credit for a user, in bytes.",
+	}, []string{uidLabel})
+
+	ExpiryDeadline = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "cloak",
+		Subsystem: "user",
+		Name:      "expiry_deadline_seconds",
+		Help:      "Unix timestamp at which a user's account expires.",
+	}, []string{uidLabel})
+)
+
+func init() {
+	prometheus.MustRegister(
+		UpBytesTotal,
+		DownBytesTotal,
+		ActiveSessions,
+		UpCreditRemaining,
+		DownCreditRemaining,
+		ExpiryDeadline,
+	)
+}
+
+// Label turns a raw UID into the string label value the metrics above are
+// keyed by.
+func Label(UID []byte) string {
+	return base64.StdEncoding.EncodeToString(UID)
+}
+
+// Handler serves the Prometheus exposition format, meant to be mounted at
+// /metrics on a configurable admin listener.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
@@ -0,0 +1,153 @@
+package server
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v2"
+
+	"github.com/cbeuw/Cloak/internal/server/usermanager"
+)
+
+// rawConfig is the on-disk shape of a Cloak server config, decodable from
+// JSON, TOML or YAML depending on the config file's extension, mirroring
+// client.rawConfig.
+type rawConfig struct {
+	BindAddr     string `json:"BindAddr" toml:"BindAddr" yaml:"BindAddr"`
+	RedirAddr    string `json:"RedirAddr" toml:"RedirAddr" yaml:"RedirAddr"`
+	PrivateKey   string `json:"PrivateKey" toml:"PrivateKey" yaml:"PrivateKey"`
+	AdminUID     string `json:"AdminUID" toml:"AdminUID" yaml:"AdminUID"`
+	DatabasePath string `json:"DatabasePath" toml:"DatabasePath" yaml:"DatabasePath"`
+	BackupRoot   string `json:"BackupRoot" toml:"BackupRoot" yaml:"BackupRoot"`
+
+	// StoreBackend and StoreEndpoints select and parametrise the
+	// usermanager.Store a Userpanel persists user records to: "bolt"
+	// (default, using DatabasePath) or "etcd"/"consul"/"redis" against
+	// StoreEndpoints, so that a fleet of servers can share state.
+	StoreBackend   string   `json:"StoreBackend" toml:"StoreBackend" yaml:"StoreBackend"`
+	StoreEndpoints []string `json:"StoreEndpoints" toml:"StoreEndpoints" yaml:"StoreEndpoints"`
+
+	// MetricsListenAddr, if non-empty, is the address a plain HTTP listener
+	// serving Prometheus metrics at /metrics is bound to. Left empty, no
+	// metrics listener is started.
+	MetricsListenAddr string `json:"MetricsListenAddr" toml:"MetricsListenAddr" yaml:"MetricsListenAddr"`
+}
+
+// Config is the parsed, validated form of rawConfig that the rest of the
+// server package consumes.
+type Config struct {
+	BindAddr          string
+	RedirAddr         string
+	PrivateKey        []byte
+	AdminUID          []byte
+	StoreConf         usermanager.StoreConfig
+	BackupRoot        string
+	MetricsListenAddr string
+}
+
+func (c *rawConfig) applyEnvOverrides() {
+	if v, ok := os.LookupEnv("CLOAK_SERVER_BINDADDR"); ok {
+		c.BindAddr = v
+	}
+	if v, ok := os.LookupEnv("CLOAK_SERVER_REDIRADDR"); ok {
+		c.RedirAddr = v
+	}
+	if v, ok := os.LookupEnv("CLOAK_SERVER_PRIVATEKEY"); ok {
+		c.PrivateKey = v
+	}
+	if v, ok := os.LookupEnv("CLOAK_SERVER_ADMINUID"); ok {
+		c.AdminUID = v
+	}
+	if v, ok := os.LookupEnv("CLOAK_SERVER_DATABASEPATH"); ok {
+		c.DatabasePath = v
+	}
+	if v, ok := os.LookupEnv("CLOAK_SERVER_BACKUPROOT"); ok {
+		c.BackupRoot = v
+	}
+	if v, ok := os.LookupEnv("CLOAK_SERVER_STOREBACKEND"); ok {
+		c.StoreBackend = v
+	}
+	if v, ok := os.LookupEnv("CLOAK_SERVER_METRICSLISTENADDR"); ok {
+		c.MetricsListenAddr = v
+	}
+}
+
+func (c *rawConfig) validate() error {
+	if c.BindAddr == "" {
+		return errors.New("BindAddr must not be empty")
+	}
+	if c.RedirAddr == "" {
+		return errors.New("RedirAddr must not be empty")
+	}
+	switch c.StoreBackend {
+	case "", "bolt":
+		if c.DatabasePath == "" {
+			return errors.New("DatabasePath must not be empty when StoreBackend is bolt")
+		}
+	case "etcd", "consul", "redis":
+		if len(c.StoreEndpoints) == 0 {
+			return errors.New("StoreEndpoints must not be empty when StoreBackend is " + c.StoreBackend)
+		}
+	default:
+		return errors.New("unknown StoreBackend: " + c.StoreBackend)
+	}
+	return nil
+}
+
+func unmarshalConfigFile(path string, c *rawConfig) error {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	switch filepath.Ext(path) {
+	case ".toml":
+		return toml.Unmarshal(content, c)
+	case ".yaml", ".yml":
+		return yaml.Unmarshal(content, c)
+	default:
+		return json.Unmarshal(content, c)
+	}
+}
+
+// ParseConfig loads a server config from a JSON, TOML or YAML file at
+// path (picked by extension, JSON by default), applies CLOAK_SERVER_*
+// environment overrides, validates it, and decodes it into a Config.
+func ParseConfig(path string) (conf Config, err error) {
+	var preParse rawConfig
+	if err = unmarshalConfigFile(path, &preParse); err != nil {
+		return conf, err
+	}
+	preParse.applyEnvOverrides()
+	if err = preParse.validate(); err != nil {
+		return conf, err
+	}
+
+	privKey, err := base64.StdEncoding.DecodeString(preParse.PrivateKey)
+	if err != nil {
+		return conf, errors.New("Failed to parse PrivateKey: " + err.Error())
+	}
+	adminUID, err := base64.StdEncoding.DecodeString(preParse.AdminUID)
+	if err != nil {
+		return conf, errors.New("Failed to parse AdminUID: " + err.Error())
+	}
+
+	conf = Config{
+		BindAddr:   preParse.BindAddr,
+		RedirAddr:  preParse.RedirAddr,
+		PrivateKey: privKey,
+		AdminUID:   adminUID,
+		StoreConf: usermanager.StoreConfig{
+			Backend:   preParse.StoreBackend,
+			Endpoints: preParse.StoreEndpoints,
+			BoltPath:  preParse.DatabasePath,
+		},
+		BackupRoot:        preParse.BackupRoot,
+		MetricsListenAddr: preParse.MetricsListenAddr,
+	}
+	return conf, nil
+}
@@ -0,0 +1,112 @@
+package usermanager
+
+import (
+	"io"
+
+	"github.com/cbeuw/Cloak/internal/auditlog"
+)
+
+// The methods below are thin exported wrappers around Userpanel's
+// unexported mutation and query methods. They exist so that
+// out-of-package callers — currently internal/adminrpc — have a stable,
+// documented surface to drive instead of reaching into package internals.
+// Every mutation is recorded to the audit log (if one is configured) with
+// the acting admin's UID, the action, and the before/after state.
+
+// SetAuditLogger installs the logger that every admin mutation below is
+// recorded to. A nil logger (the default) disables audit logging.
+func (up *Userpanel) SetAuditLogger(l *auditlog.Logger) {
+	up.auditLogger = l
+}
+
+func (up *Userpanel) AddNewUser(actorUID []byte, uinfo UserInfo) error {
+	err := up.addNewUser(uinfo)
+	up.auditLogger.Log(actorUID, "addNewUser", uinfo.UID, nil, uinfo, err)
+	return err
+}
+
+func (up *Userpanel) DelUser(actorUID, UID []byte) error {
+	before, _ := up.getUserInfo(UID)
+	err := up.delUser(UID)
+	up.auditLogger.Log(actorUID, "delUser", UID, before, nil, err)
+	return err
+}
+
+func (up *Userpanel) SetSessionsCap(actorUID, UID []byte, cap uint32) error {
+	before, _ := up.getUserInfo(UID)
+	err := up.setSessionsCap(UID, cap)
+	up.auditLogger.Log(actorUID, "setSessionsCap", UID, before.SessionsCap, cap, err)
+	return err
+}
+
+func (up *Userpanel) SetUpRate(actorUID, UID []byte, rate int64) error {
+	before, _ := up.getUserInfo(UID)
+	err := up.setUpRate(UID, rate)
+	up.auditLogger.Log(actorUID, "setUpRate", UID, before.UpRate, rate, err)
+	return err
+}
+
+func (up *Userpanel) SetDownRate(actorUID, UID []byte, rate int64) error {
+	before, _ := up.getUserInfo(UID)
+	err := up.setDownRate(UID, rate)
+	up.auditLogger.Log(actorUID, "setDownRate", UID, before.DownRate, rate, err)
+	return err
+}
+
+func (up *Userpanel) SetUpCredit(actorUID, UID []byte, n int64) error {
+	before, _ := up.getUserInfo(UID)
+	err := up.setUpCredit(UID, n)
+	up.auditLogger.Log(actorUID, "setUpCredit", UID, before.UpCredit, n, err)
+	return err
+}
+
+func (up *Userpanel) SetDownCredit(actorUID, UID []byte, n int64) error {
+	before, _ := up.getUserInfo(UID)
+	err := up.setDownCredit(UID, n)
+	up.auditLogger.Log(actorUID, "setDownCredit", UID, before.DownCredit, n, err)
+	return err
+}
+
+func (up *Userpanel) AddUpCredit(actorUID, UID []byte, delta int64) error {
+	before, _ := up.getUserInfo(UID)
+	err := up.addUpCredit(UID, delta)
+	up.auditLogger.Log(actorUID, "addUpCredit", UID, before.UpCredit, before.UpCredit+delta, err)
+	return err
+}
+
+func (up *Userpanel) AddDownCredit(actorUID, UID []byte, delta int64) error {
+	before, _ := up.getUserInfo(UID)
+	err := up.addDownCredit(UID, delta)
+	up.auditLogger.Log(actorUID, "addDownCredit", UID, before.DownCredit, before.DownCredit+delta, err)
+	return err
+}
+
+func (up *Userpanel) SetExpiryTime(actorUID, UID []byte, t int64) error {
+	before, _ := up.getUserInfo(UID)
+	err := up.setExpiryTime(UID, t)
+	up.auditLogger.Log(actorUID, "setExpiryTime", UID, before.ExpiryTime, t, err)
+	return err
+}
+
+func (up *Userpanel) ListAllUsers() []UserInfo { return up.listAllUsers() }
+
+func (up *Userpanel) ListActiveUsers() [][]byte { return up.listActiveUsers() }
+
+func (up *Userpanel) GetUserInfo(UID []byte) (UserInfo, error) { return up.getUserInfo(UID) }
+
+func (up *Userpanel) SyncMemFromDB(UID []byte) error { return up.syncMemFromDB(UID) }
+
+// HotBackup and Restore are also audited since they're destructive /
+// operationally significant even though they don't touch a single UID.
+
+func (up *Userpanel) AuditedHotBackup(actorUID []byte, w io.Writer) error {
+	err := up.HotBackup(w)
+	up.auditLogger.Log(actorUID, "hotBackup", nil, nil, nil, err)
+	return err
+}
+
+func (up *Userpanel) AuditedRestore(actorUID []byte, path string) error {
+	err := up.Restore(path)
+	up.auditLogger.Log(actorUID, "restore", nil, nil, path, err)
+	return err
+}
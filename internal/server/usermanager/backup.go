@@ -0,0 +1,160 @@
+package usermanager
+
+import (
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// scheduledBackupInterval and backupRetention control the periodic backup
+// goroutine started in MakeUserpanel: how often a snapshot is taken, and
+// how many of the most recent scheduled snapshots are kept around before
+// older ones are pruned.
+const (
+	scheduledBackupInterval = 1 * time.Hour
+	backupRetention         = 24
+)
+
+const scheduledBackupSuffix = "_scheduled.bak"
+
+// preDeleteMarker is the substring delUser's backupDB filename (built in
+// userpanel.go) always contains.
+const preDeleteMarker = "_pre_del_"
+
+// isBackupFile reports whether name matches one of the two known backup
+// naming schemes (periodic scheduled snapshots, or delUser's pre-delete
+// snapshots) produced by Store.WriteTo, rather than something else that
+// happens to live in bakRoot. bakRoot also holds the credit WAL, which is
+// fsynced on nearly every credit delta and so is almost always the most
+// recently modified file there — without this filter, anything scanning
+// bakRoot by mtime alone would pick the WAL instead of an actual backup.
+func isBackupFile(name string) bool {
+	if strings.HasSuffix(name, scheduledBackupSuffix) {
+		return true
+	}
+	return strings.Contains(name, preDeleteMarker) && strings.HasSuffix(name, ".bak")
+}
+
+// HotBackup streams a snapshot of the entire user db to w through
+// Store.WriteTo, which says which backends give a point-in-time snapshot.
+// Unlike the old backupDB, it takes no lock beyond what the
+// underlying Store's read transaction already holds, so it can run
+// concurrently with normal traffic ("hot").
+func (up *Userpanel) HotBackup(w io.Writer) error {
+	_, err := up.store.WriteTo(writeSyncerFunc(w.Write))
+	return err
+}
+
+type writeSyncerFunc func(p []byte) (int, error)
+
+func (f writeSyncerFunc) Write(p []byte) (int, error) { return f(p) }
+
+// Restore replaces the entire user db with the snapshot at path, which
+// must have been produced by HotBackup/backupDB on the same Store
+// backend.
+func (up *Userpanel) Restore(path string) error {
+	return up.store.RestoreFrom(path)
+}
+
+// runScheduledBackups takes a HotBackup snapshot once per
+// scheduledBackupInterval and prunes old ones, until stop is closed.
+func (up *Userpanel) runScheduledBackups(stop <-chan struct{}) {
+	ticker := time.NewTicker(scheduledBackupInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			name := strconv.FormatInt(time.Now().Unix(), 10) + scheduledBackupSuffix
+			if err := up.backupDB(name); err != nil {
+				log.Printf("usermanager: scheduled backup failed: %v", err)
+				continue
+			}
+			if err := pruneOldBackups(up.bakRoot, backupRetention); err != nil {
+				log.Printf("usermanager: failed to prune old backups: %v", err)
+			}
+		}
+	}
+}
+
+// pruneOldBackups keeps the `keep` most recently modified scheduled
+// backups in bakRoot and removes the rest. Pre-delete snapshots (from
+// delUser) are left alone since they're tied to a specific deletion, not
+// to the backup rotation.
+func pruneOldBackups(bakRoot string, keep int) error {
+	entries, err := ioutil.ReadDir(bakRoot)
+	if err != nil {
+		return err
+	}
+	var backups []os.FileInfo
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), scheduledBackupSuffix) {
+			backups = append(backups, e)
+		}
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].ModTime().After(backups[j].ModTime())
+	})
+	for _, e := range backups[min(keep, len(backups)):] {
+		if err := os.Remove(filepath.Join(bakRoot, e.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// latestBackupFile returns the path of the most recently modified backup
+// file (scheduled or pre-delete) in bakRoot, for use by startup corruption
+// recovery.
+func latestBackupFile(bakRoot string) (string, error) {
+	entries, err := ioutil.ReadDir(bakRoot)
+	if err != nil {
+		return "", err
+	}
+	var latest os.FileInfo
+	for _, e := range entries {
+		if e.IsDir() || !isBackupFile(e.Name()) {
+			continue
+		}
+		if latest == nil || e.ModTime().After(latest.ModTime()) {
+			latest = e
+		}
+	}
+	if latest == nil {
+		return "", os.ErrNotExist
+	}
+	return filepath.Join(bakRoot, latest.Name()), nil
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// copyFile overwrites dst with src's contents, used to restore a bolt
+// file from a backup before Store is allowed to open it.
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
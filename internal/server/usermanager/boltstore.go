@@ -0,0 +1,166 @@
+package usermanager
+
+import (
+	"io"
+	"os"
+	"strings"
+
+	"github.com/boltdb/bolt"
+)
+
+// boltStore is the default Store backend: a single local boltdb file. It
+// is what Cloak has always used, now behind the Store interface so it can
+// be swapped for a shared external backend.
+type boltStore struct {
+	db     *bolt.DB
+	dbPath string
+}
+
+func makeBoltStore(dbPath string) (Store, error) {
+	db, err := bolt.Open(dbPath, 0600, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &boltStore{db: db, dbPath: dbPath}, nil
+}
+
+// corruptionMarkers are substrings of bolt error messages that indicate
+// the file itself is damaged rather than e.g. a permissions or locking
+// problem, analogous to leveldb's errors.IsCorrupted check.
+var corruptionMarkers = []string{
+	"invalid database",
+	"checksum error",
+	"unexpected EOF",
+	bolt.ErrInvalid.Error(),
+	bolt.ErrChecksum.Error(),
+	bolt.ErrVersionMismatch.Error(),
+}
+
+// IsCorrupted reports whether err looks like it came from opening a
+// damaged bolt file, as opposed to a missing file, permission error, or
+// already-open lock.
+func IsCorrupted(err error) bool {
+	if err == nil {
+		return false
+	}
+	msg := err.Error()
+	for _, marker := range corruptionMarkers {
+		if strings.Contains(msg, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *boltStore) RestoreFrom(path string) error {
+	if err := s.db.Close(); err != nil {
+		return err
+	}
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.Create(s.dbPath)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		return err
+	}
+	db, err := bolt.Open(s.dbPath, 0600, nil)
+	if err != nil {
+		return err
+	}
+	s.db = db
+	return nil
+}
+
+func (s *boltStore) CreateBucket(UID []byte) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucket(UID)
+		if err == bolt.ErrBucketExists {
+			return ErrUserExists
+		}
+		return err
+	})
+}
+
+func (s *boltStore) DeleteBucket(UID []byte) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		err := tx.DeleteBucket(UID)
+		if err == bolt.ErrBucketNotFound {
+			return ErrUserNotFound
+		}
+		return err
+	})
+}
+
+func (s *boltStore) Get(UID []byte, key string) ([]byte, error) {
+	var ret []byte
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(UID)
+		if b == nil {
+			return ErrUserNotFound
+		}
+		ret = b.Get([]byte(key))
+		return nil
+	})
+	return ret, err
+}
+
+func (s *boltStore) Put(UID []byte, key string, value []byte) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(UID)
+		if b == nil {
+			return ErrUserNotFound
+		}
+		return b.Put([]byte(key), value)
+	})
+}
+
+func (s *boltStore) Update(UID []byte, fn func(tx StoreTx) error) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(UID)
+		if b == nil {
+			return ErrUserNotFound
+		}
+		return fn(boltTx{b})
+	})
+}
+
+func (s *boltStore) ForEach(fn func(UID []byte, b StoreReader) error) error {
+	return s.db.View(func(tx *bolt.Tx) error {
+		return tx.ForEach(func(UID []byte, b *bolt.Bucket) error {
+			return fn(UID, boltTx{b})
+		})
+	})
+}
+
+func (s *boltStore) WriteTo(w WriteSyncer) (int64, error) {
+	var n int64
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		n, err = tx.WriteTo(w)
+		return err
+	})
+	return n, err
+}
+
+func (s *boltStore) Close() error {
+	return s.db.Close()
+}
+
+// boltTx adapts a *bolt.Bucket to StoreTx/StoreReader.
+type boltTx struct {
+	b *bolt.Bucket
+}
+
+func (t boltTx) Get(key string) []byte { return t.b.Get([]byte(key)) }
+func (t boltTx) Put(key string, value []byte) error {
+	return t.b.Put([]byte(key), value)
+}
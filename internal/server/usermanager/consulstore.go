@@ -0,0 +1,233 @@
+package usermanager
+
+import (
+	"encoding/hex"
+	"errors"
+	"strings"
+
+	"github.com/hashicorp/consul/api"
+)
+
+var errConsulCASFailed = errors.New("consul: concurrent modification, CAS failed")
+
+// maxUpdateRetries bounds how many times Update re-reads and re-applies
+// fn after losing a CAS race to a concurrent writer, mirroring the
+// auto-retry etcd's concurrency.STM already does for etcdStore.Update so
+// callers like addUpCredit/addDownCredit don't have to special-case this
+// backend.
+const maxUpdateRetries = 10
+
+// consulStore stores each user field as "cloak/users/<UID>/<field>" under
+// Consul's KV store, using a check-and-set on every changed key, committed
+// in one Consul transaction, to get transaction-like semantics for Update.
+type consulStore struct {
+	kv     *api.KV
+	prefix string
+}
+
+const consulKeyPrefix = "cloak/users/"
+
+func makeConsulStore(endpoints []string) (Store, error) {
+	conf := api.DefaultConfig()
+	if len(endpoints) > 0 {
+		conf.Address = endpoints[0]
+	}
+	cli, err := api.NewClient(conf)
+	if err != nil {
+		return nil, err
+	}
+	return &consulStore{kv: cli.KV(), prefix: consulKeyPrefix}, nil
+}
+
+// userPrefix hex-encodes UID before building the key: UID is 32 arbitrary
+// bytes, so embedding it as a raw string would let a UID containing a '/'
+// byte collide with the "/"-delimited field-name split ForEach relies on.
+// Hex guarantees the prefix only ever contains [0-9a-f].
+func (s *consulStore) userPrefix(UID []byte) string {
+	return s.prefix + hex.EncodeToString(UID) + "/"
+}
+
+func (s *consulStore) CreateBucket(UID []byte) error {
+	pairs, _, err := s.kv.List(s.userPrefix(UID), nil)
+	if err != nil {
+		return err
+	}
+	if len(pairs) > 0 {
+		return ErrUserExists
+	}
+	_, err = s.kv.Put(&api.KVPair{Key: s.userPrefix(UID) + "\x00exists", Value: []byte("1")}, nil)
+	return err
+}
+
+func (s *consulStore) DeleteBucket(UID []byte) error {
+	pairs, _, err := s.kv.List(s.userPrefix(UID), nil)
+	if err != nil {
+		return err
+	}
+	if len(pairs) == 0 {
+		return ErrUserNotFound
+	}
+	_, err = s.kv.DeleteTree(s.userPrefix(UID), nil)
+	return err
+}
+
+func (s *consulStore) Get(UID []byte, key string) ([]byte, error) {
+	pairs, _, err := s.kv.List(s.userPrefix(UID), nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(pairs) == 0 {
+		return nil, ErrUserNotFound
+	}
+	pair, _, err := s.kv.Get(s.userPrefix(UID)+key, nil)
+	if err != nil {
+		return nil, err
+	}
+	if pair == nil {
+		return nil, nil
+	}
+	return pair.Value, nil
+}
+
+func (s *consulStore) Put(UID []byte, key string, value []byte) error {
+	pairs, _, err := s.kv.List(s.userPrefix(UID), nil)
+	if err != nil {
+		return err
+	}
+	if len(pairs) == 0 {
+		return ErrUserNotFound
+	}
+	_, err = s.kv.Put(&api.KVPair{Key: s.userPrefix(UID) + key, Value: value}, nil)
+	return err
+}
+
+// Update reads the whole bucket, lets fn mutate an in-memory copy, then
+// writes back changed keys in one Consul transaction with a CAS check on
+// each one, so a concurrent writer that touched any of them between our
+// read and write aborts the whole write.
+// On a CAS conflict it re-reads the bucket and re-applies fn up to
+// maxUpdateRetries times rather than surfacing errConsulCASFailed to the
+// caller, the same way etcdStore.Update retries via concurrency.STM.
+func (s *consulStore) Update(UID []byte, fn func(tx StoreTx) error) error {
+	var err error
+	for attempt := 0; attempt < maxUpdateRetries; attempt++ {
+		err = s.tryUpdate(UID, fn)
+		if err != errConsulCASFailed {
+			return err
+		}
+	}
+	return err
+}
+
+func (s *consulStore) tryUpdate(UID []byte, fn func(tx StoreTx) error) error {
+	pairs, _, err := s.kv.List(s.userPrefix(UID), nil)
+	if err != nil {
+		return err
+	}
+	if len(pairs) == 0 {
+		return ErrUserNotFound
+	}
+	tx := &consulTx{prefix: s.userPrefix(UID), orig: make(map[string]*api.KVPair)}
+	for _, p := range pairs {
+		field := strings.TrimPrefix(p.Key, tx.prefix)
+		tx.orig[field] = p
+	}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	ops := make(api.KVTxnOps, 0, len(tx.dirty))
+	for field, value := range tx.dirty {
+		orig := tx.orig[field]
+		modifyIndex := uint64(0)
+		if orig != nil {
+			modifyIndex = orig.ModifyIndex
+		}
+		ops = append(ops, &api.KVTxnOp{Verb: api.KVCAS, Key: tx.prefix + field, Value: value, Index: modifyIndex})
+	}
+	if len(ops) == 0 {
+		return nil
+	}
+	ok, _, _, err := s.kv.Txn(ops, nil)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errConsulCASFailed
+	}
+	return nil
+}
+
+func (s *consulStore) ForEach(fn func(UID []byte, b StoreReader) error) error {
+	pairs, _, err := s.kv.List(s.prefix, nil)
+	if err != nil {
+		return err
+	}
+	fields := make(map[string]map[string][]byte)
+	for _, p := range pairs {
+		rest := strings.TrimPrefix(p.Key, s.prefix)
+		parts := strings.SplitN(rest, "/", 2)
+		if _, ok := fields[parts[0]]; !ok {
+			fields[parts[0]] = make(map[string][]byte)
+		}
+		if len(parts) == 2 && parts[1] != "\x00exists" {
+			fields[parts[0]][parts[1]] = p.Value
+		}
+	}
+	for hexUID, kv := range fields {
+		UID, err := hex.DecodeString(hexUID)
+		if err != nil {
+			continue
+		}
+		if err := fn(UID, mapReader(kv)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *consulStore) WriteTo(w WriteSyncer) (int64, error) {
+	pairs, _, err := s.kv.List(s.prefix, nil)
+	if err != nil {
+		return 0, err
+	}
+	var total int64
+	for _, p := range pairs {
+		line := p.Key + "\t" + string(p.Value) + "\n"
+		n, err := w.Write([]byte(line))
+		total += int64(n)
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+func (s *consulStore) RestoreFrom(path string) error {
+	return ErrRestoreNotSupported
+}
+
+func (s *consulStore) Close() error { return nil }
+
+type consulTx struct {
+	prefix string
+	orig   map[string]*api.KVPair
+	dirty  map[string][]byte
+}
+
+func (t *consulTx) Get(key string) []byte {
+	if v, ok := t.dirty[key]; ok {
+		return v
+	}
+	if p, ok := t.orig[key]; ok {
+		return p.Value
+	}
+	return nil
+}
+
+func (t *consulTx) Put(key string, value []byte) error {
+	if t.dirty == nil {
+		t.dirty = make(map[string][]byte)
+	}
+	t.dirty[key] = value
+	return nil
+}
@@ -0,0 +1,207 @@
+package usermanager
+
+import (
+	"context"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"go.etcd.io/etcd/clientv3"
+	"go.etcd.io/etcd/clientv3/concurrency"
+)
+
+// etcdStore keeps one key per user field, namespaced as
+// "cloak/users/<UID>/<field>", so that a bucket is just a key prefix.
+// Update uses etcd's STM to get the same read-modify-write atomicity that
+// a bolt transaction gives us locally.
+type etcdStore struct {
+	cli    *clientv3.Client
+	prefix string
+}
+
+const etcdKeyPrefix = "cloak/users/"
+
+func makeEtcdStore(endpoints []string) (Store, error) {
+	cli, err := clientv3.New(clientv3.Config{
+		Endpoints:   endpoints,
+		DialTimeout: 5 * time.Second,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &etcdStore{cli: cli, prefix: etcdKeyPrefix}, nil
+}
+
+// userPrefix hex-encodes UID before building the key: UID is 32 arbitrary
+// bytes, so embedding it as a raw string would let a UID containing a '/'
+// byte collide with the "/"-delimited field-name split ForEach relies on.
+// Hex guarantees the prefix only ever contains [0-9a-f].
+func (s *etcdStore) userPrefix(UID []byte) string {
+	return s.prefix + hex.EncodeToString(UID) + "/"
+}
+
+func (s *etcdStore) CreateBucket(UID []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	resp, err := s.cli.Get(ctx, s.userPrefix(UID), clientv3.WithPrefix(), clientv3.WithCountOnly())
+	if err != nil {
+		return err
+	}
+	if resp.Count > 0 {
+		return ErrUserExists
+	}
+	// a sentinel key marks the bucket as existing even before any field is written
+	_, err = s.cli.Put(ctx, s.userPrefix(UID)+"\x00exists", "1")
+	return err
+}
+
+func (s *etcdStore) DeleteBucket(UID []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	resp, err := s.cli.Delete(ctx, s.userPrefix(UID), clientv3.WithPrefix())
+	if err != nil {
+		return err
+	}
+	if resp.Deleted == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+func (s *etcdStore) Get(UID []byte, key string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	exists, err := s.cli.Get(ctx, s.userPrefix(UID), clientv3.WithPrefix(), clientv3.WithCountOnly())
+	if err != nil {
+		return nil, err
+	}
+	if exists.Count == 0 {
+		return nil, ErrUserNotFound
+	}
+	resp, err := s.cli.Get(ctx, s.userPrefix(UID)+key)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Kvs) == 0 {
+		return nil, nil
+	}
+	return resp.Kvs[0].Value, nil
+}
+
+func (s *etcdStore) Put(UID []byte, key string, value []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	exists, err := s.cli.Get(ctx, s.userPrefix(UID), clientv3.WithPrefix(), clientv3.WithCountOnly())
+	if err != nil {
+		return err
+	}
+	if exists.Count == 0 {
+		return ErrUserNotFound
+	}
+	_, err = s.cli.Put(ctx, s.userPrefix(UID)+key, string(value))
+	return err
+}
+
+func (s *etcdStore) Update(UID []byte, fn func(tx StoreTx) error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// concurrency.NewSTM manages its own session/lease internally and
+	// auto-retries the apply function on a conflicting write, so there's
+	// no separate session to open here.
+	_, err := concurrency.NewSTM(s.cli, func(stm concurrency.STM) error {
+		prefix := s.userPrefix(UID)
+		if stm.Get(prefix+"\x00exists") == "" {
+			return ErrUserNotFound
+		}
+		return fn(etcdTx{stm: stm, prefix: prefix})
+	}, concurrency.WithAbortContext(ctx))
+	return err
+}
+
+func (s *etcdStore) ForEach(fn func(UID []byte, b StoreReader) error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	resp, err := s.cli.Get(ctx, s.prefix, clientv3.WithPrefix())
+	if err != nil {
+		return err
+	}
+	fields := make(map[string]map[string][]byte)
+	for _, kv := range resp.Kvs {
+		rest := strings.TrimPrefix(string(kv.Key), s.prefix)
+		parts := strings.SplitN(rest, "/", 2)
+		if len(parts) != 2 || parts[1] == "\x00exists" {
+			if len(parts) == 2 {
+				if _, ok := fields[parts[0]]; !ok {
+					fields[parts[0]] = make(map[string][]byte)
+				}
+			}
+			continue
+		}
+		if _, ok := fields[parts[0]]; !ok {
+			fields[parts[0]] = make(map[string][]byte)
+		}
+		fields[parts[0]][parts[1]] = kv.Value
+	}
+	for hexUID, kv := range fields {
+		UID, err := hex.DecodeString(hexUID)
+		if err != nil {
+			continue
+		}
+		if err := fn(UID, mapReader(kv)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *etcdStore) WriteTo(w WriteSyncer) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	resp, err := s.cli.Get(ctx, s.prefix, clientv3.WithPrefix())
+	if err != nil {
+		return 0, err
+	}
+	var total int64
+	for _, kv := range resp.Kvs {
+		line := string(kv.Key) + "\t" + string(kv.Value) + "\n"
+		n, err := w.Write([]byte(line))
+		total += int64(n)
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+func (s *etcdStore) RestoreFrom(path string) error {
+	return ErrRestoreNotSupported
+}
+
+func (s *etcdStore) Close() error {
+	return s.cli.Close()
+}
+
+type etcdTx struct {
+	stm    concurrency.STM
+	prefix string
+}
+
+func (t etcdTx) Get(key string) []byte {
+	v := t.stm.Get(t.prefix + key)
+	if v == "" {
+		return nil
+	}
+	return []byte(v)
+}
+
+func (t etcdTx) Put(key string, value []byte) error {
+	t.stm.Put(t.prefix+key, string(value))
+	return nil
+}
+
+// mapReader adapts a plain map to StoreReader for backends that fetch an
+// entire bucket's fields in one round trip.
+type mapReader map[string][]byte
+
+func (m mapReader) Get(key string) []byte { return m[key] }
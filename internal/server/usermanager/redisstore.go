@@ -0,0 +1,200 @@
+package usermanager
+
+import (
+	"github.com/go-redis/redis"
+)
+
+// redisStore keeps one hash per user, keyed "cloak:users:<UID>", with the
+// user's fields as hash fields. Update runs inside a WATCH/MULTI so that a
+// concurrent writer touching the same hash aborts and is retried, the same
+// optimistic-concurrency shape Redis recommends for read-modify-write.
+type redisStore struct {
+	cli    *redis.Client
+	prefix string
+}
+
+const redisKeyPrefix = "cloak:users:"
+
+func makeRedisStore(endpoints []string) (Store, error) {
+	addr := "127.0.0.1:6379"
+	if len(endpoints) > 0 {
+		addr = endpoints[0]
+	}
+	cli := redis.NewClient(&redis.Options{Addr: addr})
+	if err := cli.Ping().Err(); err != nil {
+		return nil, err
+	}
+	return &redisStore{cli: cli, prefix: redisKeyPrefix}, nil
+}
+
+func (s *redisStore) userKey(UID []byte) string {
+	return s.prefix + string(UID)
+}
+
+func (s *redisStore) CreateBucket(UID []byte) error {
+	exists, err := s.cli.Exists(s.userKey(UID)).Result()
+	if err != nil {
+		return err
+	}
+	if exists > 0 {
+		return ErrUserExists
+	}
+	return s.cli.HSet(s.userKey(UID), "\x00exists", "1").Err()
+}
+
+func (s *redisStore) DeleteBucket(UID []byte) error {
+	n, err := s.cli.Del(s.userKey(UID)).Result()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+func (s *redisStore) Get(UID []byte, key string) ([]byte, error) {
+	exists, err := s.cli.Exists(s.userKey(UID)).Result()
+	if err != nil {
+		return nil, err
+	}
+	if exists == 0 {
+		return nil, ErrUserNotFound
+	}
+	v, err := s.cli.HGet(s.userKey(UID), key).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	return v, err
+}
+
+func (s *redisStore) Put(UID []byte, key string, value []byte) error {
+	exists, err := s.cli.Exists(s.userKey(UID)).Result()
+	if err != nil {
+		return err
+	}
+	if exists == 0 {
+		return ErrUserNotFound
+	}
+	return s.cli.HSet(s.userKey(UID), key, value).Err()
+}
+
+// Update runs fn inside a WATCH/MULTI. On TxFailedErr (a concurrent
+// writer touched the watched key between our read and the MULTI) it
+// re-reads and re-applies fn up to maxUpdateRetries times instead of
+// surfacing the conflict to the caller, the same way etcdStore.Update
+// retries via concurrency.STM.
+func (s *redisStore) Update(UID []byte, fn func(tx StoreTx) error) error {
+	key := s.userKey(UID)
+	watchFn := func(tx *redis.Tx) error {
+		exists, err := tx.Exists(key).Result()
+		if err != nil {
+			return err
+		}
+		if exists == 0 {
+			return ErrUserNotFound
+		}
+		fields, err := tx.HGetAll(key).Result()
+		if err != nil {
+			return err
+		}
+		orig := make(map[string][]byte, len(fields))
+		for k, v := range fields {
+			orig[k] = []byte(v)
+		}
+		rtx := &redisTx{orig: orig}
+		if err := fn(rtx); err != nil {
+			return err
+		}
+		if len(rtx.dirty) == 0 {
+			return nil
+		}
+		_, err = tx.Pipelined(func(pipe redis.Pipeliner) error {
+			for k, v := range rtx.dirty {
+				pipe.HSet(key, k, v)
+			}
+			return nil
+		})
+		return err
+	}
+	var err error
+	for attempt := 0; attempt < maxUpdateRetries; attempt++ {
+		err = s.cli.Watch(watchFn, key)
+		if err != redis.TxFailedErr {
+			return err
+		}
+	}
+	return err
+}
+
+func (s *redisStore) ForEach(fn func(UID []byte, b StoreReader) error) error {
+	iter := s.cli.Scan(0, s.prefix+"*", 0).Iterator()
+	for iter.Next() {
+		key := iter.Val()
+		fields, err := s.cli.HGetAll(key).Result()
+		if err != nil {
+			return err
+		}
+		kv := make(map[string][]byte, len(fields))
+		for k, v := range fields {
+			if k == "\x00exists" {
+				continue
+			}
+			kv[k] = []byte(v)
+		}
+		UID := []byte(key[len(s.prefix):])
+		if err := fn(UID, mapReader(kv)); err != nil {
+			return err
+		}
+	}
+	return iter.Err()
+}
+
+func (s *redisStore) WriteTo(w WriteSyncer) (int64, error) {
+	var total int64
+	iter := s.cli.Scan(0, s.prefix+"*", 0).Iterator()
+	for iter.Next() {
+		key := iter.Val()
+		fields, err := s.cli.HGetAll(key).Result()
+		if err != nil {
+			return total, err
+		}
+		for k, v := range fields {
+			line := key + "\t" + k + "\t" + v + "\n"
+			n, err := w.Write([]byte(line))
+			total += int64(n)
+			if err != nil {
+				return total, err
+			}
+		}
+	}
+	return total, iter.Err()
+}
+
+func (s *redisStore) RestoreFrom(path string) error {
+	return ErrRestoreNotSupported
+}
+
+func (s *redisStore) Close() error {
+	return s.cli.Close()
+}
+
+type redisTx struct {
+	orig  map[string][]byte
+	dirty map[string][]byte
+}
+
+func (t *redisTx) Get(key string) []byte {
+	if v, ok := t.dirty[key]; ok {
+		return v
+	}
+	return t.orig[key]
+}
+
+func (t *redisTx) Put(key string, value []byte) error {
+	if t.dirty == nil {
+		t.dirty = make(map[string][]byte)
+	}
+	t.dirty[key] = value
+	return nil
+}
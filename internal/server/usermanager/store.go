@@ -0,0 +1,105 @@
+package usermanager
+
+import "errors"
+
+// Store abstracts over the key-value backend that Userpanel persists user
+// records in. Each user is identified by its UID and occupies one bucket;
+// within a bucket, fields such as UpCredit or ExpiryTime are stored as
+// individual keys. This lets Cloak run against a local embedded db (bolt)
+// or a shared external store (etcd, Consul, Redis) so that a fleet of
+// servers can present the same user/credit state, mirroring the way
+// stolon abstracts its cluster state behind libkv.
+type Store interface {
+	// CreateBucket creates a new, empty bucket for UID. It returns
+	// ErrUserExists if the bucket is already present.
+	CreateBucket(UID []byte) error
+
+	// DeleteBucket removes UID's bucket and everything in it. It returns
+	// ErrUserNotFound if the bucket does not exist.
+	DeleteBucket(UID []byte) error
+
+	// Get fetches the value of key in UID's bucket. It returns
+	// ErrUserNotFound if the bucket does not exist, and a nil slice if the
+	// key is unset.
+	Get(UID []byte, key string) ([]byte, error)
+
+	// Put sets key to value in UID's bucket. It returns ErrUserNotFound if
+	// the bucket does not exist.
+	Put(UID []byte, key string, value []byte) error
+
+	// Update runs fn in a single atomic read-modify-write transaction
+	// scoped to UID's bucket, so that concurrent Updates to the same user
+	// cannot interleave. It returns ErrUserNotFound if the bucket does not
+	// exist.
+	Update(UID []byte, fn func(tx StoreTx) error) error
+
+	// ForEach calls fn once per existing bucket, passing the UID and a
+	// reader scoped to that bucket. Iteration stops at the first error fn
+	// returns.
+	ForEach(fn func(UID []byte, b StoreReader) error) error
+
+	// WriteTo streams a snapshot of the entire store in a backend-specific
+	// format. Only the bolt backend's RestoreFrom can read it back; the
+	// others return ErrRestoreNotSupported. The bolt, etcd and Consul
+	// snapshots each come from a single read; the Redis one reads each
+	// user's hash separately while scanning, so it is not point-in-time
+	// across users.
+	WriteTo(w WriteSyncer) (n int64, err error)
+
+	// RestoreFrom replaces the store's entire contents with the snapshot
+	// at path, as previously written by WriteTo. It returns
+	// ErrRestoreNotSupported for backends that cannot restore a full
+	// snapshot from a file (anything that isn't a local bolt file).
+	RestoreFrom(path string) error
+
+	Close() error
+}
+
+// StoreReader is a read-only view over a single user's bucket.
+type StoreReader interface {
+	Get(key string) []byte
+}
+
+// StoreTx is a read-modify-write view over a single user's bucket, valid
+// only for the duration of the Update callback that receives it.
+type StoreTx interface {
+	StoreReader
+	Put(key string, value []byte) error
+}
+
+// WriteSyncer is the subset of io.Writer that backup destinations need;
+// kept as its own type so Store doesn't have to import io just for this.
+type WriteSyncer interface {
+	Write(p []byte) (n int, err error)
+}
+
+var ErrUserExists = errors.New("user already exists in db")
+var ErrRestoreNotSupported = errors.New("this Store backend does not support RestoreFrom")
+
+// StoreConfig selects and parametrises a Store backend. Backend is one of
+// "bolt" (default), "etcd", "consul" or "redis"; Endpoints is a list of
+// host:port addresses for the external backends and is ignored for bolt.
+type StoreConfig struct {
+	Backend   string
+	Endpoints []string
+
+	// BoltPath is the path to the bolt file, used only when Backend is
+	// "bolt" or empty.
+	BoltPath string
+}
+
+// MakeStore constructs the Store selected by conf.
+func MakeStore(conf StoreConfig) (Store, error) {
+	switch conf.Backend {
+	case "", "bolt":
+		return makeBoltStore(conf.BoltPath)
+	case "etcd":
+		return makeEtcdStore(conf.Endpoints)
+	case "consul":
+		return makeConsulStore(conf.Endpoints)
+	case "redis":
+		return makeRedisStore(conf.Endpoints)
+	default:
+		return nil, errors.New("unknown StoreBackend: " + conf.Backend)
+	}
+}
@@ -4,13 +4,15 @@ import (
 	"encoding/base64"
 	"encoding/binary"
 	"errors"
+	"log"
 	"os"
 	"path"
 	"strconv"
 	"sync"
 	"time"
 
-	"github.com/boltdb/bolt"
+	"github.com/cbeuw/Cloak/internal/auditlog"
+	"github.com/cbeuw/Cloak/internal/metrics"
 )
 
 var Uint32 = binary.BigEndian.Uint32
@@ -20,58 +22,249 @@ var PutUint32 = binary.BigEndian.PutUint32
 var PutUint64 = binary.BigEndian.PutUint64
 
 type Userpanel struct {
-	db      *bolt.DB
+	store   Store
 	bakRoot string
 
 	activeUsersM sync.RWMutex
 	activeUsers  map[[32]byte]*User
+
+	creditDeltaCh chan creditDelta
+	wal           *creditWAL
+
+	stopScheduledBackups chan struct{}
+
+	auditLogger *auditlog.Logger
 }
 
-func MakeUserpanel(dbPath, bakRoot string) (*Userpanel, error) {
-	db, err := bolt.Open(dbPath, 0600, nil)
-	if err != nil {
-		return nil, err
-	}
+const creditWALFileName = "credit.wal"
+
+// creditFlushInterval is how often pending credit deltas are batched into
+// a single db transaction per user. It replaces the old fixed 10-second
+// full-scan: instead of every user's credit being rewritten every tick
+// regardless of activity, only users who actually pushed a delta since
+// the last flush get written, and at a finer grain.
+const creditFlushInterval = 2 * time.Second
+
+// creditDelta is one user's consumption since its last push, tagged with
+// the microsecond timestamp at which it was observed so that flushing can
+// compute real elapsed time rather than relying on wall-clock polling.
+type creditDelta struct {
+	UID       [32]byte
+	upDelta   int64
+	downDelta int64
+	tsMicro   int64
+}
+
+// MakeUserpanel opens the configured Store backend (bolt by default),
+// recovering from a corrupted bolt file by falling back to the newest
+// backup in bakRoot if necessary, replays any credit WAL left over from
+// an unclean shutdown, and starts the credit writeback and scheduled
+// backup goroutines. storeConf.Backend and storeConf.Endpoints are
+// normally sourced from a server config's StoreBackend/StoreEndpoints
+// fields, so that a fleet of Cloak servers can be pointed at the same
+// etcd/Consul/Redis cluster instead of each keeping its own local bolt
+// file.
+func MakeUserpanel(storeConf StoreConfig, bakRoot string) (*Userpanel, error) {
 	if bakRoot == "" {
 		os.Mkdir("db-backup", 0777)
 		bakRoot = "db-backup"
 	}
 	bakRoot = path.Clean(bakRoot)
-	up := &Userpanel{
-		db:          db,
-		bakRoot:     bakRoot,
-		activeUsers: make(map[[32]byte]*User),
-	}
-	go func() {
-		for {
-			time.Sleep(time.Second * 10)
-			up.updateCredits()
+
+	store, err := MakeStore(storeConf)
+	if err != nil {
+		if !isBoltBackend(storeConf) || !IsCorrupted(err) {
+			return nil, err
+		}
+		log.Printf("usermanager: db appears corrupted (%v), attempting recovery from latest backup in %s", err, bakRoot)
+		latest, berr := latestBackupFile(bakRoot)
+		if berr != nil {
+			return nil, err
 		}
-	}()
+		if cerr := copyFile(latest, storeConf.BoltPath); cerr != nil {
+			return nil, err
+		}
+		store, err = MakeStore(storeConf)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("usermanager: recovered db from backup %s", latest)
+	}
+
+	walPath := path.Join(bakRoot, creditWALFileName)
+	if err := replayCreditWAL(walPath, store); err != nil {
+		return nil, err
+	}
+	wal, err := openCreditWAL(walPath)
+	if err != nil {
+		return nil, err
+	}
+
+	up := &Userpanel{
+		store:                store,
+		bakRoot:              bakRoot,
+		activeUsers:          make(map[[32]byte]*User),
+		creditDeltaCh:        make(chan creditDelta, 1024),
+		wal:                  wal,
+		stopScheduledBackups: make(chan struct{}),
+	}
+	go up.runCreditWriter()
+	go up.runCreditPoller()
+	go up.runScheduledBackups(up.stopScheduledBackups)
 	return up, nil
 }
 
-// credits of all users are updated together so that there is only 1 goroutine managing it
-func (up *Userpanel) updateCredits() {
-	up.activeUsersM.RLock()
-	for _, u := range up.activeUsers {
-		up.db.Update(func(tx *bolt.Tx) error {
-			b := tx.Bucket(u.arrUID[:])
-			if b == nil {
-				return ErrUserNotFound
+func isBoltBackend(conf StoreConfig) bool {
+	return conf.Backend == "" || conf.Backend == "bolt"
+}
+
+// nowMicro returns the current time as a monotonic-ish microsecond Unix
+// timestamp, the same granularity credit deltas are tagged with.
+func nowMicro() int64 {
+	return timeToUnixMicro(time.Now())
+}
+
+func timeToUnixMicro(t time.Time) int64 {
+	return t.Unix()*1e6 + int64(t.Nanosecond())/1e3
+}
+
+// PushCreditDelta queues one user's up/down credit delta for the single
+// writer goroutine started in MakeUserpanel; runCreditPoller calls it with
+// each active user's change in valve credit. It does not touch storage
+// itself, but once creditDeltaCh's buffer is full the send blocks until
+// runCreditWriter drains it. Since only that goroutine applies deltas,
+// concurrent pushes cannot race with each other or with the batched flush.
+func (up *Userpanel) PushCreditDelta(UID [32]byte, upDelta, downDelta int64) {
+	up.creditDeltaCh <- creditDelta{UID: UID, upDelta: upDelta, downDelta: downDelta, tsMicro: nowMicro()}
+}
+
+// creditPollInterval is how often runCreditPoller samples each active
+// user's valve.
+const creditPollInterval = time.Second
+
+// valveCredit is a snapshot of a valve's remaining up/down credit at one
+// poll, the same pair of counters the old fixed-interval updateCredits
+// used to copy straight into the store.
+type valveCredit struct {
+	up, down int64
+}
+
+// runCreditPoller is what actually drives PushCreditDelta off live
+// traffic: a User's valve only tracks its own remaining credit, it
+// doesn't push deltas itself, so this loop samples every active user's
+// valve once per creditPollInterval, diffs against the previous sample,
+// and pushes the consumption (or top-up) as a delta. This runs alongside
+// runCreditWriter instead of writing to the store directly the way the
+// old updateCredits did, so every consumed byte still goes through the
+// WAL and batched flush.
+func (up *Userpanel) runCreditPoller() {
+	last := make(map[[32]byte]valveCredit)
+	ticker := time.NewTicker(creditPollInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		next := make(map[[32]byte]valveCredit)
+		// deltas are collected while activeUsersM is held and pushed only
+		// after it's released: PushCreditDelta sends on the buffered
+		// creditDeltaCh, and if runCreditWriter stalls (e.g. a wedged
+		// remote Store) that send can block indefinitely. Doing it under
+		// RLock would starve every other activeUsersM writer
+		// (GetAndActivateUser, delActiveUser, ...) server-wide.
+		var deltas []creditDelta
+		up.activeUsersM.RLock()
+		for arrUID, u := range up.activeUsers {
+			cur := valveCredit{up: u.valve.GetRxCredit(), down: u.valve.GetTxCredit()}
+			if prev, ok := last[arrUID]; ok && cur != prev {
+				deltas = append(deltas, creditDelta{UID: arrUID, upDelta: cur.up - prev.up, downDelta: cur.down - prev.down})
 			}
-			if err := b.Put([]byte("UpCredit"), i64ToB(u.valve.GetRxCredit())); err != nil {
-				return err
+			next[arrUID] = cur
+		}
+		up.activeUsersM.RUnlock()
+		for _, d := range deltas {
+			up.PushCreditDelta(d.UID, d.upDelta, d.downDelta)
+		}
+		last = next
+	}
+}
+
+// runCreditWriter is the single goroutine that owns pending credit state:
+// it drains creditDeltaCh as deltas arrive, fsyncing each one to the
+// credit WAL first so an unclean shutdown between flushes can replay it,
+// and once per creditFlushInterval applies everything accumulated so far
+// in one Store transaction per affected UID. A UID whose flush fails with
+// anything other than ErrUserNotFound stays in `pending` to be retried on
+// the next tick instead of being dropped. After every tick the WAL is
+// rewritten to hold exactly what's still in `pending`: a UID that
+// flushed successfully this tick must not leave a stale record behind,
+// since applyCreditDelta is an additive read-modify-write and a crash
+// before the next clean flush would replay and double-apply it. Because
+// only this goroutine touches `pending`, no locking is needed around it.
+func (up *Userpanel) runCreditWriter() {
+	pending := make(map[[32]byte]creditDelta)
+	ticker := time.NewTicker(creditFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case d := <-up.creditDeltaCh:
+			if err := up.wal.append(d); err != nil {
+				log.Printf("usermanager: failed to append credit delta to WAL: %v", err)
 			}
-			if err := b.Put([]byte("DownCredit"), i64ToB(u.valve.GetTxCredit())); err != nil {
-				return err
+			acc := pending[d.UID]
+			acc.UID = d.UID
+			acc.upDelta += d.upDelta
+			acc.downDelta += d.downDelta
+			acc.tsMicro = d.tsMicro
+			pending[d.UID] = acc
+		case <-ticker.C:
+			if len(pending) == 0 {
+				continue
 			}
-			return nil
-
-		})
+			for UID, d := range pending {
+				err := applyCreditDelta(up.store, UID, d)
+				if err == nil || err == ErrUserNotFound {
+					delete(pending, UID)
+					continue
+				}
+				log.Printf("usermanager: failed to flush credit delta for user: %v", err)
+			}
+			if err := up.wal.rewrite(pending); err != nil {
+				log.Printf("usermanager: failed to compact credit WAL: %v", err)
+			}
+		}
 	}
-	up.activeUsersM.RUnlock()
+}
 
+// applyCreditDelta applies one user's accumulated delta to the store as a
+// single atomic read-modify-write, the same addUpCredit/addDownCredit
+// shape used elsewhere (deltas are negative for consumption, positive for
+// top-ups), so a set*Credit call racing with a flush can never observe or
+// produce a torn value. It's shared between the live writer goroutine and
+// WAL replay on startup.
+func applyCreditDelta(store Store, UID [32]byte, d creditDelta) error {
+	var upCredit, downCredit int64
+	err := store.Update(UID[:], func(tx StoreTx) error {
+		upCredit = int64(Uint64(tx.Get("UpCredit"))) + d.upDelta
+		downCredit = int64(Uint64(tx.Get("DownCredit"))) + d.downDelta
+		if err := tx.Put("UpCredit", i64ToB(upCredit)); err != nil {
+			return err
+		}
+		if err := tx.Put("DownCredit", i64ToB(downCredit)); err != nil {
+			return err
+		}
+		return tx.Put("LastSeenMicro", i64ToB(d.tsMicro))
+	})
+	if err != nil {
+		return err
+	}
+	label := metrics.Label(UID[:])
+	if d.upDelta < 0 {
+		metrics.UpBytesTotal.WithLabelValues(label).Add(float64(-d.upDelta))
+	}
+	if d.downDelta < 0 {
+		metrics.DownBytesTotal.WithLabelValues(label).Add(float64(-d.downDelta))
+	}
+	metrics.UpCreditRemaining.WithLabelValues(label).Set(float64(upCredit))
+	metrics.DownCreditRemaining.WithLabelValues(label).Set(float64(downCredit))
+	return nil
 }
 
 func (up *Userpanel) backupDB(bakFileName string) error {
@@ -87,16 +280,21 @@ func (up *Userpanel) backupDB(bakFileName string) error {
 			return err
 		}
 	}
-	err = up.db.View(func(tx *bolt.Tx) error {
-		_, err := tx.WriteTo(bak)
-		if err != nil {
-			return err
-		}
-		return nil
-	})
+	_, err = up.store.WriteTo(bak)
 	return err
 }
 
+// Close stops the scheduled backup goroutine and closes the WAL and the
+// underlying Store. The credit writer and credit poller goroutines are not
+// stopped.
+func (up *Userpanel) Close() error {
+	close(up.stopScheduledBackups)
+	if err := up.wal.close(); err != nil {
+		return err
+	}
+	return up.store.Close()
+}
+
 var ErrUserNotFound = errors.New("User does not exist in db")
 var ErrUserNotActive = errors.New("User is not active")
 
@@ -110,18 +308,20 @@ func (up *Userpanel) GetAndActivateAdminUser(AdminUID []byte) (*User, error) {
 	}
 
 	uinfo := UserInfo{
-		UID:         AdminUID,
-		SessionsCap: 1e9,
-		UpRate:      1e12,
-		DownRate:    1e12,
-		UpCredit:    1e15,
-		DownCredit:  1e15,
-		ExpiryTime:  1e15,
+		UID:           AdminUID,
+		SessionsCap:   1e9,
+		UpRate:        1e12,
+		DownRate:      1e12,
+		UpCredit:      1e15,
+		DownCredit:    1e15,
+		ExpiryTime:    1e15,
+		LastSeenMicro: nowMicro(),
 	}
 
 	user := MakeUser(up, &uinfo)
 	up.activeUsers[arrUID] = user
 	up.activeUsersM.Unlock()
+	metrics.ActiveSessions.WithLabelValues(metrics.Label(AdminUID)).Set(1)
 	return user, nil
 }
 
@@ -138,19 +338,7 @@ func (up *Userpanel) GetAndActivateUser(UID []byte) (*User, error) {
 
 	var uinfo UserInfo
 	uinfo.UID = UID
-	err := up.db.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket(UID[:])
-		if b == nil {
-			return ErrUserNotFound
-		}
-		uinfo.SessionsCap = Uint32(b.Get([]byte("SessionsCap")))
-		uinfo.UpRate = int64(Uint64(b.Get([]byte("UpRate"))))
-		uinfo.DownRate = int64(Uint64(b.Get([]byte("DownRate"))))
-		uinfo.UpCredit = int64(Uint64(b.Get([]byte("UpCredit")))) // reee brackets
-		uinfo.DownCredit = int64(Uint64(b.Get([]byte("DownCredit"))))
-		uinfo.ExpiryTime = int64(Uint64(b.Get([]byte("ExpiryTime"))))
-		return nil
-	})
+	err := up.readUserInfo(UID, &uinfo)
 	if err != nil {
 		up.activeUsersM.Unlock()
 		return nil, err
@@ -158,35 +346,68 @@ func (up *Userpanel) GetAndActivateUser(UID []byte) (*User, error) {
 	u := MakeUser(up, &uinfo)
 	up.activeUsers[arrUID] = u
 	up.activeUsersM.Unlock()
+	metrics.ActiveSessions.WithLabelValues(metrics.Label(UID)).Set(1)
 	return u, nil
 }
 
+// readUserInfo fills in every field of uinfo except UID from the store.
+func (up *Userpanel) readUserInfo(UID []byte, uinfo *UserInfo) error {
+	sessionsCap, err := up.store.Get(UID, "SessionsCap")
+	if err != nil {
+		return err
+	}
+	upRate, err := up.store.Get(UID, "UpRate")
+	if err != nil {
+		return err
+	}
+	downRate, err := up.store.Get(UID, "DownRate")
+	if err != nil {
+		return err
+	}
+	upCredit, err := up.store.Get(UID, "UpCredit")
+	if err != nil {
+		return err
+	}
+	downCredit, err := up.store.Get(UID, "DownCredit")
+	if err != nil {
+		return err
+	}
+	expiryTime, err := up.store.Get(UID, "ExpiryTime")
+	if err != nil {
+		return err
+	}
+	lastSeenMicro, err := up.store.Get(UID, "LastSeenMicro")
+	if err != nil {
+		return err
+	}
+	uinfo.SessionsCap = Uint32(sessionsCap)
+	uinfo.UpRate = int64(Uint64(upRate))
+	uinfo.DownRate = int64(Uint64(downRate))
+	uinfo.UpCredit = int64(Uint64(upCredit))
+	uinfo.DownCredit = int64(Uint64(downCredit))
+	uinfo.ExpiryTime = int64(Uint64(expiryTime))
+	uinfo.LastSeenMicro = int64(Uint64(lastSeenMicro))
+	return nil
+}
+
+// IsInactive reports whether UID has not pushed a credit delta within the
+// past `since` duration, using LastSeenMicro rather than wall-clock
+// polling so it reflects real elapsed time even if the server was briefly
+// paused or overloaded.
+func (up *Userpanel) IsInactive(UID []byte, since time.Duration) (bool, error) {
+	lastSeenMicro, err := up.store.Get(UID, "LastSeenMicro")
+	if err != nil {
+		return false, err
+	}
+	return nowMicro()-int64(Uint64(lastSeenMicro)) > since.Microseconds(), nil
+}
+
 func (up *Userpanel) updateDBEntryUint32(UID []byte, key string, value uint32) error {
-	err := up.db.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket(UID)
-		if b == nil {
-			return ErrUserNotFound
-		}
-		if err := b.Put([]byte(key), u32ToB(value)); err != nil {
-			return err
-		}
-		return nil
-	})
-	return err
+	return up.store.Put(UID, key, u32ToB(value))
 }
 
 func (up *Userpanel) updateDBEntryInt64(UID []byte, key string, value int64) error {
-	err := up.db.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket(UID)
-		if b == nil {
-			return ErrUserNotFound
-		}
-		if err := b.Put([]byte(key), i64ToB(value)); err != nil {
-			return err
-		}
-		return nil
-	})
-	return err
+	return up.store.Put(UID, key, i64ToB(value))
 }
 
 // This is used when all sessions of a user close
@@ -196,6 +417,7 @@ func (up *Userpanel) delActiveUser(UID []byte) {
 	up.activeUsersM.Lock()
 	delete(up.activeUsers, arrUID)
 	up.activeUsersM.Unlock()
+	metrics.ActiveSessions.WithLabelValues(metrics.Label(UID)).Set(0)
 }
 
 func (up *Userpanel) getActiveUser(UID []byte) *User {
@@ -221,23 +443,21 @@ func (up *Userpanel) listActiveUsers() [][]byte {
 
 func (up *Userpanel) listAllUsers() []UserInfo {
 	var ret []UserInfo
-	up.db.View(func(tx *bolt.Tx) error {
-		tx.ForEach(func(UID []byte, b *bolt.Bucket) error {
-			// if we want to avoid writing every single key out,
-			// we would have to either make UserInfo a map,
-			// or use reflect.
-			// neither is convinient
-			var uinfo UserInfo
-			uinfo.UID = UID
-			uinfo.SessionsCap = Uint32(b.Get([]byte("SessionsCap")))
-			uinfo.UpRate = int64(Uint64(b.Get([]byte("UpRate"))))
-			uinfo.DownRate = int64(Uint64(b.Get([]byte("DownRate"))))
-			uinfo.UpCredit = int64(Uint64(b.Get([]byte("UpCredit"))))
-			uinfo.DownCredit = int64(Uint64(b.Get([]byte("DownCredit"))))
-			uinfo.ExpiryTime = int64(Uint64(b.Get([]byte("ExpiryTime"))))
-			ret = append(ret, uinfo)
-			return nil
-		})
+	up.store.ForEach(func(UID []byte, b StoreReader) error {
+		// if we want to avoid writing every single key out,
+		// we would have to either make UserInfo a map,
+		// or use reflect.
+		// neither is convinient
+		var uinfo UserInfo
+		uinfo.UID = UID
+		uinfo.SessionsCap = Uint32(b.Get("SessionsCap"))
+		uinfo.UpRate = int64(Uint64(b.Get("UpRate")))
+		uinfo.DownRate = int64(Uint64(b.Get("DownRate")))
+		uinfo.UpCredit = int64(Uint64(b.Get("UpCredit")))
+		uinfo.DownCredit = int64(Uint64(b.Get("DownCredit")))
+		uinfo.ExpiryTime = int64(Uint64(b.Get("ExpiryTime")))
+		uinfo.LastSeenMicro = int64(Uint64(b.Get("LastSeenMicro")))
+		ret = append(ret, uinfo)
 		return nil
 	})
 	return ret
@@ -245,20 +465,8 @@ func (up *Userpanel) listAllUsers() []UserInfo {
 
 func (up *Userpanel) getUserInfo(UID []byte) (UserInfo, error) {
 	var uinfo UserInfo
-	err := up.db.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket(UID)
-		if b == nil {
-			return ErrUserNotFound
-		}
-		uinfo.UID = UID
-		uinfo.SessionsCap = Uint32(b.Get([]byte("SessionsCap")))
-		uinfo.UpRate = int64(Uint64(b.Get([]byte("UpRate"))))
-		uinfo.DownRate = int64(Uint64(b.Get([]byte("DownRate"))))
-		uinfo.UpCredit = int64(Uint64(b.Get([]byte("UpCredit"))))
-		uinfo.DownCredit = int64(Uint64(b.Get([]byte("DownCredit"))))
-		uinfo.ExpiryTime = int64(Uint64(b.Get([]byte("ExpiryTime"))))
-		return nil
-	})
+	uinfo.UID = UID
+	err := up.readUserInfo(UID, &uinfo)
 	return uinfo, err
 }
 
@@ -279,32 +487,43 @@ func i64ToB(value int64) []byte {
 }
 
 func (up *Userpanel) addNewUser(uinfo UserInfo) error {
-	err := up.db.Update(func(tx *bolt.Tx) error {
-		b, err := tx.CreateBucket(uinfo.UID[:])
-		if err != nil {
-			return err
-		}
-		if err = b.Put([]byte("SessionsCap"), u32ToB(uinfo.SessionsCap)); err != nil {
+	if err := up.store.CreateBucket(uinfo.UID[:]); err != nil {
+		return err
+	}
+	// All seven fields are written in a single Update transaction, not
+	// sequential Puts, so that a reader racing the creation (or a
+	// mid-sequence RPC failure on a remote backend) never observes a
+	// bucket with only some keys set: readUserInfo/listAllUsers decode
+	// every key unconditionally and would panic on a missing one.
+	err := up.store.Update(uinfo.UID[:], func(tx StoreTx) error {
+		if err := tx.Put("SessionsCap", u32ToB(uinfo.SessionsCap)); err != nil {
 			return err
 		}
-		if err = b.Put([]byte("UpRate"), i64ToB(uinfo.UpRate)); err != nil {
+		if err := tx.Put("UpRate", i64ToB(uinfo.UpRate)); err != nil {
 			return err
 		}
-		if err = b.Put([]byte("DownRate"), i64ToB(uinfo.DownRate)); err != nil {
+		if err := tx.Put("DownRate", i64ToB(uinfo.DownRate)); err != nil {
 			return err
 		}
-		if err = b.Put([]byte("UpCredit"), i64ToB(uinfo.UpCredit)); err != nil {
+		if err := tx.Put("UpCredit", i64ToB(uinfo.UpCredit)); err != nil {
 			return err
 		}
-		if err = b.Put([]byte("DownCredit"), i64ToB(uinfo.DownCredit)); err != nil {
+		if err := tx.Put("DownCredit", i64ToB(uinfo.DownCredit)); err != nil {
 			return err
 		}
-		if err = b.Put([]byte("ExpiryTime"), i64ToB(uinfo.ExpiryTime)); err != nil {
+		if err := tx.Put("ExpiryTime", i64ToB(uinfo.ExpiryTime)); err != nil {
 			return err
 		}
-		return nil
+		return tx.Put("LastSeenMicro", i64ToB(nowMicro()))
 	})
-	return err
+	if err != nil {
+		return err
+	}
+	label := metrics.Label(uinfo.UID[:])
+	metrics.UpCreditRemaining.WithLabelValues(label).Set(float64(uinfo.UpCredit))
+	metrics.DownCreditRemaining.WithLabelValues(label).Set(float64(uinfo.DownCredit))
+	metrics.ExpiryDeadline.WithLabelValues(label).Set(float64(uinfo.ExpiryTime))
+	return nil
 }
 
 func (up *Userpanel) delUser(UID []byte) error {
@@ -312,28 +531,13 @@ func (up *Userpanel) delUser(UID []byte) error {
 	if err != nil {
 		return err
 	}
-	err = up.db.Update(func(tx *bolt.Tx) error {
-		return tx.DeleteBucket(UID)
-	})
-	return err
+	return up.store.DeleteBucket(UID)
 }
 
 func (up *Userpanel) syncMemFromDB(UID []byte) error {
 	var uinfo UserInfo
-	err := up.db.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket(UID)
-		if b == nil {
-			return ErrUserNotFound
-		}
-		uinfo.UID = UID
-		uinfo.SessionsCap = Uint32(b.Get([]byte("SessionsCap")))
-		uinfo.UpRate = int64(Uint64(b.Get([]byte("UpRate"))))
-		uinfo.DownRate = int64(Uint64(b.Get([]byte("DownRate"))))
-		uinfo.UpCredit = int64(Uint64(b.Get([]byte("UpCredit"))))
-		uinfo.DownCredit = int64(Uint64(b.Get([]byte("DownCredit"))))
-		uinfo.ExpiryTime = int64(Uint64(b.Get([]byte("ExpiryTime"))))
-		return nil
-	})
+	uinfo.UID = UID
+	err := up.readUserInfo(UID, &uinfo)
 	if err != nil {
 		return err
 	}
@@ -391,6 +595,7 @@ func (up *Userpanel) setUpCredit(UID []byte, n int64) error {
 	if err != nil {
 		return err
 	}
+	metrics.UpCreditRemaining.WithLabelValues(metrics.Label(UID)).Set(float64(n))
 	u := up.getActiveUser(UID)
 	if u == nil {
 		return nil
@@ -403,6 +608,7 @@ func (up *Userpanel) setDownCredit(UID []byte, n int64) error {
 	if err != nil {
 		return err
 	}
+	metrics.DownCreditRemaining.WithLabelValues(metrics.Label(UID)).Set(float64(n))
 	u := up.getActiveUser(UID)
 	if u == nil {
 		return nil
@@ -416,6 +622,7 @@ func (up *Userpanel) setExpiryTime(UID []byte, time int64) error {
 	if err != nil {
 		return err
 	}
+	metrics.ExpiryDeadline.WithLabelValues(metrics.Label(UID)).Set(float64(time))
 	u := up.getActiveUser(UID)
 	if u == nil {
 		return nil
@@ -425,21 +632,16 @@ func (up *Userpanel) setExpiryTime(UID []byte, time int64) error {
 }
 
 func (up *Userpanel) addUpCredit(UID []byte, delta int64) error {
-	err := up.db.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket(UID)
-		if b == nil {
-			return ErrUserNotFound
-		}
-		old := b.Get([]byte("UpCredit"))
-		new := int64(Uint64(old)) + delta
-		if err := b.Put([]byte("UpCredit"), i64ToB(new)); err != nil {
-			return err
-		}
-		return nil
+	var newCredit int64
+	err := up.store.Update(UID, func(tx StoreTx) error {
+		old := tx.Get("UpCredit")
+		newCredit = int64(Uint64(old)) + delta
+		return tx.Put("UpCredit", i64ToB(newCredit))
 	})
 	if err != nil {
 		return err
 	}
+	metrics.UpCreditRemaining.WithLabelValues(metrics.Label(UID)).Set(float64(newCredit))
 	u := up.getActiveUser(UID)
 	if u == nil {
 		return nil
@@ -449,21 +651,16 @@ func (up *Userpanel) addUpCredit(UID []byte, delta int64) error {
 }
 
 func (up *Userpanel) addDownCredit(UID []byte, delta int64) error {
-	err := up.db.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket(UID)
-		if b == nil {
-			return ErrUserNotFound
-		}
-		old := b.Get([]byte("DownCredit"))
-		new := int64(Uint64(old)) + delta
-		if err := b.Put([]byte("DownCredit"), i64ToB(new)); err != nil {
-			return err
-		}
-		return nil
+	var newCredit int64
+	err := up.store.Update(UID, func(tx StoreTx) error {
+		old := tx.Get("DownCredit")
+		newCredit = int64(Uint64(old)) + delta
+		return tx.Put("DownCredit", i64ToB(newCredit))
 	})
 	if err != nil {
 		return err
 	}
+	metrics.DownCreditRemaining.WithLabelValues(metrics.Label(UID)).Set(float64(newCredit))
 	u := up.getActiveUser(UID)
 	if u == nil {
 		return nil
@@ -0,0 +1,140 @@
+package usermanager
+
+import (
+	"io"
+	"os"
+)
+
+// creditWALRecordLen is the fixed size of one WAL record: a 32-byte UID
+// followed by three big-endian int64s (upDelta, downDelta, tsMicro).
+const creditWALRecordLen = 32 + 8 + 8 + 8
+
+// creditWAL is a lightweight append-only log of credit deltas between
+// full flushes, so that a crash inside the creditFlushInterval window
+// doesn't silently lose accounting data: every delta is fsynced here
+// before it's merely held in the writer goroutine's in-memory pending
+// map, and the log is reset once those deltas have been durably applied
+// to the Store.
+type creditWAL struct {
+	f *os.File
+}
+
+func openCreditWAL(path string) (*creditWAL, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return &creditWAL{f: f}, nil
+}
+
+func encodeCreditWALRecord(d creditDelta) [creditWALRecordLen]byte {
+	var rec [creditWALRecordLen]byte
+	copy(rec[0:32], d.UID[:])
+	PutUint64(rec[32:40], uint64(d.upDelta))
+	PutUint64(rec[40:48], uint64(d.downDelta))
+	PutUint64(rec[48:56], uint64(d.tsMicro))
+	return rec
+}
+
+func (w *creditWAL) append(d creditDelta) error {
+	rec := encodeCreditWALRecord(d)
+	if _, err := w.f.Write(rec[:]); err != nil {
+		return err
+	}
+	return w.f.Sync()
+}
+
+// rewrite replaces the WAL's contents with exactly the records in
+// pending. runCreditWriter calls it after every flush tick, whether the
+// flush fully or partially succeeded: deltas already applied to the Store
+// this tick must not
+// survive in the WAL (a crash before the next full flush would replay
+// and double-apply them, since applyCreditDelta is additive, not
+// idempotent), while deltas still waiting for a retry must.
+func (w *creditWAL) rewrite(pending map[[32]byte]creditDelta) error {
+	if err := w.f.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := w.f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	for _, d := range pending {
+		rec := encodeCreditWALRecord(d)
+		if _, err := w.f.Write(rec[:]); err != nil {
+			return err
+		}
+	}
+	return w.f.Sync()
+}
+
+// reset truncates the WAL once its contents have been durably merged
+// into the Store, so it doesn't grow unboundedly and isn't replayed
+// again on the next startup.
+func (w *creditWAL) reset() error {
+	if err := w.f.Truncate(0); err != nil {
+		return err
+	}
+	_, err := w.f.Seek(0, io.SeekStart)
+	return err
+}
+
+func (w *creditWAL) close() error {
+	return w.f.Close()
+}
+
+// replayCreditWAL reads every record left over in the WAL at path (if
+// any) from an unclean shutdown, merges them per-UID the same way the
+// writer goroutine would have, and applies them to store before normal
+// service starts. On success it leaves the WAL empty behind it; if
+// applying a merged delta fails, it returns that error and leaves the WAL
+// as it was.
+func replayCreditWAL(path string, store Store) error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	pending := make(map[[32]byte]creditDelta)
+	var rec [creditWALRecordLen]byte
+	for {
+		_, err := io.ReadFull(f, rec[:])
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			// a torn trailing record from a crash mid-write; stop replay
+			// here rather than fail it outright, since everything up to
+			// this point is still good.
+			break
+		}
+		var d creditDelta
+		copy(d.UID[:], rec[0:32])
+		d.upDelta = int64(Uint64(rec[32:40]))
+		d.downDelta = int64(Uint64(rec[40:48]))
+		d.tsMicro = int64(Uint64(rec[48:56]))
+
+		acc := pending[d.UID]
+		acc.UID = d.UID
+		acc.upDelta += d.upDelta
+		acc.downDelta += d.downDelta
+		acc.tsMicro = d.tsMicro
+		pending[d.UID] = acc
+	}
+
+	for UID, d := range pending {
+		if err := applyCreditDelta(store, UID, d); err != nil && err != ErrUserNotFound {
+			return err
+		}
+	}
+
+	wal, err := openCreditWAL(path)
+	if err != nil {
+		return err
+	}
+	defer wal.close()
+	return wal.reset()
+}